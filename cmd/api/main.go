@@ -12,7 +12,9 @@ import (
 	"github.com/emandor/lemme_service/internal/config"
 	"github.com/emandor/lemme_service/internal/db"
 	"github.com/emandor/lemme_service/internal/middleware"
+	"github.com/emandor/lemme_service/internal/providers"
 	"github.com/emandor/lemme_service/internal/quiz"
+	"github.com/emandor/lemme_service/internal/storage"
 	"github.com/emandor/lemme_service/internal/telemetry"
 	"github.com/emandor/lemme_service/internal/ws"
 )
@@ -34,7 +36,7 @@ func main() {
 		return
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{BodyLimit: cfg.BodyLimit()})
 
 	app.Use(middleware.RateLimiter())
 	app.Use(middleware.RequestID())
@@ -49,20 +51,45 @@ func main() {
 	app.Get("/healthz", func(c *fiber.Ctx) error {
 		return c.SendString("ok")
 	})
-	app.Static("/storage", "./storage")
 	app.Get("/api/v1/auth/google/login", authReg.GoogleLogin)
 	app.Get("/api/v1/auth/google/callback", authReg.GoogleCallback)
 
-	qh := quiz.NewHandler(cfg, sqlxDB, rdb)
+	blob := storage.MustOpen(cfg)
+	app.Get(storage.RoutePrefix+"*", storage.Serve(blob, storage.NewSigner(cfg.SessionCookieSecret, cfg.BaseURL)))
+
+	qh := quiz.NewHandler(cfg, sqlxDB, rdb, blob)
+	qh.StartPurgeJob()
 	protected := app.Group("/api/v1", middleware.AuthSession(authReg))
 
 	protected.Post("/auth/logout", authReg.Logout)
 	protected.Get("/me", authReg.Me)
+	protected.Put("/me/preferences", authReg.UpdatePreferences)
 
 	protected.Post("/quizzes", middleware.FileUploadValidator(cfg), qh.CreateQuiz)
 	protected.Get("/quizzes", qh.ListMyQuizzes)
+	protected.Get("/quizzes/search", qh.SearchQuizzes)
+	protected.Post("/quizzes/batch", middleware.PDFUploadValidator(cfg), qh.CreateBatch)
+	protected.Get("/quizzes/batch/:id", qh.GetBatch)
 	protected.Get("/quizzes/:id", qh.GetQuiz)
 	protected.Get("/quizzes/:id/answers", qh.ListAnswers)
+	protected.Delete("/quizzes/:id", qh.DeleteQuiz)
+	protected.Post("/quizzes/:id/restore", qh.RestoreQuiz)
+	protected.Post("/quizzes/:id/crop", qh.CropQuiz)
+	protected.Put("/quizzes/:id/title", qh.SetTitle)
+	protected.Post("/quizzes/:id/title/generate", qh.GenerateTitle)
+	protected.Put("/quizzes/:id/tags", qh.SetQuizTags)
+	protected.Put("/quizzes/:id/folders", qh.SetQuizFolders)
+
+	protected.Get("/tags", qh.ListTags)
+	protected.Post("/tags", qh.CreateTag)
+	protected.Delete("/tags/:labelID", qh.DeleteTag)
+	protected.Get("/folders", qh.ListFolders)
+	protected.Post("/folders", qh.CreateFolder)
+	protected.Delete("/folders/:labelID", qh.DeleteFolder)
+
+	protected.Get("/providers/stats", func(c *fiber.Ctx) error {
+		return c.JSON(providers.Stats())
+	})
 
 	app.Get("/ws", websocket.New(ws.HandleWS))
 
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/emandor/lemme_service/internal/config"
+	"github.com/emandor/lemme_service/internal/lang"
 	"github.com/emandor/lemme_service/internal/middleware"
 	"github.com/emandor/lemme_service/internal/telemetry"
 	"github.com/gofiber/fiber/v2"
@@ -67,8 +68,10 @@ func (r *Registry) Me(c *fiber.Ctx) error {
 		CreatedAt time.Time `db:"created_at" json:"created_at"`
 		QuizQuota int       `db:"quiz_quota" json:"quiz_quota"`
 		QuizUsed  int       `db:"quiz_used" json:"quiz_used"`
+		// AnswerLang is empty when answers follow the quiz language.
+		AnswerLang string `db:"answer_lang" json:"answer_lang"`
 	}
-	err := r.db.Get(&user, `SELECT id, email, name, picture, created_at, quiz_used, quiz_quota FROM users WHERE id=? LIMIT 1`, uid)
+	err := r.db.Get(&user, `SELECT id, email, name, picture, created_at, quiz_used, quiz_quota, COALESCE(answer_lang,'') AS answer_lang FROM users WHERE id=? LIMIT 1`, uid)
 	if err != nil {
 		return c.Status(500).SendString("db error")
 	}
@@ -76,6 +79,26 @@ func (r *Registry) Me(c *fiber.Ctx) error {
 
 }
 
+// UpdatePreferences sets the user's preferred answer language ("eng",
+// "ind", ...); an empty value answers in the language of each quiz.
+func (r *Registry) UpdatePreferences(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(int64)
+	var body struct {
+		AnswerLang string `json:"answer_lang"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+	code := strings.TrimSpace(body.AnswerLang)
+	if code != "" && !lang.Supported(code) {
+		return c.Status(400).SendString("unsupported language")
+	}
+	if _, err := r.db.Exec(`UPDATE users SET answer_lang=NULLIF(?,''), updated_at=NOW() WHERE id=?`, code, uid); err != nil {
+		return c.Status(500).SendString("db error")
+	}
+	return c.JSON(fiber.Map{"answer_lang": code})
+}
+
 func (r *Registry) GoogleLogin(c *fiber.Ctx) error {
 	log := telemetry.L()
 	log.Info().
@@ -26,6 +26,11 @@ type Config struct {
 	OpenAIKey, OpenAIModel       string
 	AnthropicKey, AnthropicModel string
 	GeminiKey, GeminiModel       string
+	TitleProvider                string
+	// TitleModel is the model GenerateTitle asks, a cheap one of the title
+	// provider by default; TitleMaxTokens bounds its reply.
+	TitleModel     string
+	TitleMaxTokens int
 
 	OCRLang         string
 	OCREngine       string
@@ -34,58 +39,197 @@ type Config struct {
 	OCRImgMaxW      int
 	OCRImgQuality   int
 	OCRImgGrayscale bool
-	OCRCacheTTL     time.Duration
+	// OCRPrepSteps are the img.PrepareForOCR steps for OCREngine, from
+	// OCR_PREP_STEPS_<ENGINE> or OCR_PREP_STEPS.
+	OCRPrepSteps []string
+	OCRCacheTTL  time.Duration
+	// OCRMode is the default reading of quiz images: plain, or math for
+	// text with LaTeX formulas.
+	OCRMode string
+
+	StorageDriver   string
+	StorageLocalDir string
+	StorageURLTTL   time.Duration
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3PathStyle     bool
+
+	QuizDedupe       bool
+	QuizDedupeWindow time.Duration
+	// QuizDedupePHashDist is the max perceptual-hash distance treated as the
+	// same screenshot by dedupe; negative disables near-duplicate matching.
+	QuizDedupePHashDist int
+	ImgPHashMaxDist     int
+	QuizRestoreWindow   time.Duration
+	QuizPurgeInterval   time.Duration
+	QuizMaxImages       int
+	// QuizInputMode is the default of what providers see of a quiz: text
+	// (OCR), vision (images) or hybrid (both).
+	QuizInputMode string
 
 	OpenAIRPS          int
 	OpenAIBurst        int
 	ProviderMaxRetries int
+	// ProviderTimeout bounds a single provider HTTP attempt.
+	ProviderTimeout time.Duration
+	// ProviderRPS and ProviderBurst rate-limit each answer provider.
+	ProviderRPS   int
+	ProviderBurst int
+	// ProviderBreakerThreshold consecutive failures open a provider's
+	// circuit for ProviderBreakerCooldown.
+	ProviderBreakerThreshold int
+	ProviderBreakerCooldown  time.Duration
+	// ProviderStrategy is the default fan-out: all, first-k, fallback or
+	// hedge. ProviderFirstK and ProviderHedgeDelay tune the latter two.
+	ProviderStrategy   string
+	ProviderFirstK     int
+	ProviderHedgeDelay time.Duration
+	// PromptTemplateDir holds <kind>/<version>.tmpl prompt templates;
+	// PromptWeights ("single/v2=20,...") overrides their A/B weights.
+	PromptTemplateDir    string
+	PromptWeights        []string
+	PromptReloadInterval time.Duration
+	// ProviderStructured uses each provider's native structured-output
+	// mode with the shared answer schema.
+	ProviderStructured bool
+	// ProviderStream relays answers token by token to WS subscribers.
+	ProviderStream bool
+	// Generation settings per provider (see providers.Generation). A
+	// temperature that is not a number ("none") is left out of requests.
+	// Gemini 2.5 models think by default out of GeminiMaxTokens, hence
+	// its larger default.
+	OpenAIMaxTokens         int
+	OpenAITemperature       *float64
+	OpenAIReasoningEffort   string
+	AnthropicMaxTokens      int
+	AnthropicTemperature    *float64
+	AnthropicThinkingBudget int
+	GeminiMaxTokens         int
+	GeminiTemperature       *float64
+	GeminiThinkingBudget    int
 
+	// MaxBodyLimit is the minimum request body limit in MB; see BodyLimit.
 	MaxBodyLimit       int
 	AllowedMaxFileSize int
 	AllowedFileExt     []string
+	// PDFRenderCmd renders worksheet pages (pdftoppm compatible); empty
+	// disables batch uploads.
+	PDFRenderCmd   string
+	PDFMaxPages    int
+	PDFRenderDPI   int
+	PDFMaxFileSize int
+	// HEIFDecoderCmd converts HEIC/HEIF uploads to JPEG; empty disables them.
+	HEIFDecoderCmd string
 }
 
 func Load() *Config {
 	_ = godotenv.Load()
 
 	c := &Config{
-		AppEnv:              get("APP_ENV", "dev"),
-		AppPort:             get("APP_PORT", "8080"),
-		BaseURL:             get("APP_BASE_URL", "http://localhost:8080"),
-		DBDSN:               must("DB_DSN"),
-		RedisAddr:           get("REDIS_ADDR", "127.0.0.1:6379"),
-		RedisDB:             atoi(get("REDIS_DB", "0")),
-		SessionCookieName:   get("SESSION_COOKIE_NAME", "lemme_sid"),
-		SessionCookieSecret: must("SESSION_COOKIE_SECRET"),
-		JWTSecret:           must("JWT_SECRET"),
-		CORSOrigins:         split(get("CORS_ORIGINS", "http://localhost:5173")),
-		GoogleClientID:      must("GOOGLE_CLIENT_ID"),
-		GoogleClientSecret:  must("GOOGLE_CLIENT_SECRET"),
-		GoogleRedirectURL:   must("GOOGLE_REDIRECT_URL"),
-		OAuthAllowedDomains: split(get("OAUTH_ALLOWED_DOMAINS", "")),
-		OpenAIKey:           get("OPENAI_API_KEY", ""),
-		OpenAIModel:         get("OPENAI_MODEL", "gpt-4o-mini"),
-		AnthropicKey:        get("ANTHROPIC_API_KEY", ""),
-		AnthropicModel:      get("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest"),
-		GeminiKey:           get("GEMINI_API_KEY", ""),
-		GeminiModel:         get("GEMINI_MODEL", "gemini-2.5-pro"),
-		OCRLang:             get("OCR_LANG", "eng+ind"),
-		OCREngine:           get("OCR_ENGINE", "openai"),
-		OCROpenAIModel:      get("OCR_OPENAI_MODEL", "gpt-4o-mini"),
-		OCROpenAIKey:        get("OCR_OPENAI_KEY", get("OCR_OPENAI_MODEL", "")),
-		OCRImgMaxW:          atoi(get("OCR_IMG_MAX_W", "1024")),
-		OCRImgQuality:       atoi(get("OCR_IMG_QUALITY", "60")),
-		OCRImgGrayscale:     parseBool(get("OCR_IMG_GRAYSCALE", "true")),
-		OCRCacheTTL:         mustDuration(get("OCR_CACHE_TTL", "168h")),
-		OpenAIRPS:           atoi(get("OPENAI_RPS", "2")),
-		OpenAIBurst:         atoi(get("OPENAI_BURST", "2")),
-		ProviderMaxRetries:  atoi(get("PROVIDER_MAX_RETRIES", "3")),
-		AllowedMaxFileSize:  GetEnvInt("ALLOWED_MAX_FILE_SIZE", 2),
-		AllowedFileExt:      GetEnvList("ALLOWED_FILE_EXT", []string{".jpg", ".jpeg", ".png"}),
+		AppEnv:                   get("APP_ENV", "dev"),
+		AppPort:                  get("APP_PORT", "8080"),
+		BaseURL:                  get("APP_BASE_URL", "http://localhost:8080"),
+		DBDSN:                    must("DB_DSN"),
+		RedisAddr:                get("REDIS_ADDR", "127.0.0.1:6379"),
+		RedisDB:                  atoi(get("REDIS_DB", "0")),
+		SessionCookieName:        get("SESSION_COOKIE_NAME", "lemme_sid"),
+		SessionCookieSecret:      must("SESSION_COOKIE_SECRET"),
+		JWTSecret:                must("JWT_SECRET"),
+		CORSOrigins:              split(get("CORS_ORIGINS", "http://localhost:5173")),
+		GoogleClientID:           must("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:       must("GOOGLE_CLIENT_SECRET"),
+		GoogleRedirectURL:        must("GOOGLE_REDIRECT_URL"),
+		OAuthAllowedDomains:      split(get("OAUTH_ALLOWED_DOMAINS", "")),
+		OpenAIKey:                get("OPENAI_API_KEY", ""),
+		OpenAIModel:              get("OPENAI_MODEL", "gpt-4o-mini"),
+		AnthropicKey:             get("ANTHROPIC_API_KEY", ""),
+		AnthropicModel:           get("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest"),
+		GeminiKey:                get("GEMINI_API_KEY", ""),
+		GeminiModel:              get("GEMINI_MODEL", "gemini-2.5-pro"),
+		TitleProvider:            get("TITLE_PROVIDER", ""),
+		TitleModel:               get("TITLE_MODEL", ""),
+		TitleMaxTokens:           GetEnvInt("TITLE_MAX_TOKENS", 32),
+		OCRLang:                  get("OCR_LANG", "eng+ind"),
+		OCREngine:                get("OCR_ENGINE", "openai"),
+		OCROpenAIModel:           get("OCR_OPENAI_MODEL", "gpt-4o-mini"),
+		OCROpenAIKey:             get("OCR_OPENAI_KEY", get("OCR_OPENAI_MODEL", "")),
+		OCRImgMaxW:               atoi(get("OCR_IMG_MAX_W", "1024")),
+		OCRImgQuality:            atoi(get("OCR_IMG_QUALITY", "60")),
+		OCRImgGrayscale:          parseBool(get("OCR_IMG_GRAYSCALE", "true")),
+		OCRCacheTTL:              mustDuration(get("OCR_CACHE_TTL", "168h")),
+		OCRMode:                  get("OCR_MODE", "plain"),
+		StorageDriver:            get("STORAGE_DRIVER", "local"),
+		StorageLocalDir:          get("STORAGE_LOCAL_DIR", "./storage"),
+		StorageURLTTL:            mustDuration(get("STORAGE_URL_TTL", "15m")),
+		S3Endpoint:               get("S3_ENDPOINT", ""),
+		S3Region:                 get("S3_REGION", "us-east-1"),
+		S3Bucket:                 get("S3_BUCKET", ""),
+		S3AccessKey:              get("S3_ACCESS_KEY", ""),
+		S3SecretKey:              get("S3_SECRET_KEY", ""),
+		S3PathStyle:              parseBool(get("S3_PATH_STYLE", "true")),
+		QuizDedupe:               parseBool(get("QUIZ_DEDUPE", "false")),
+		QuizDedupeWindow:         mustDuration(get("QUIZ_DEDUPE_WINDOW", "720h")),
+		QuizDedupePHashDist:      GetEnvInt("QUIZ_DEDUPE_PHASH_DIST", 3),
+		ImgPHashMaxDist:          GetEnvInt("IMG_PHASH_MAX_DIST", 10),
+		QuizRestoreWindow:        mustDuration(get("QUIZ_RESTORE_WINDOW", "72h")),
+		QuizPurgeInterval:        mustDuration(get("QUIZ_PURGE_INTERVAL", "1h")),
+		QuizMaxImages:            GetEnvInt("QUIZ_MAX_IMAGES", 3),
+		QuizInputMode:            get("QUIZ_INPUT_MODE", "text"),
+		OpenAIRPS:                atoi(get("OPENAI_RPS", "2")),
+		OpenAIBurst:              atoi(get("OPENAI_BURST", "2")),
+		ProviderMaxRetries:       atoi(get("PROVIDER_MAX_RETRIES", "3")),
+		ProviderTimeout:          mustDuration(get("PROVIDER_TIMEOUT", "60s")),
+		ProviderRPS:              GetEnvInt("PROVIDER_RPS", 2),
+		ProviderBurst:            GetEnvInt("PROVIDER_BURST", 2),
+		ProviderBreakerThreshold: GetEnvInt("PROVIDER_BREAKER_THRESHOLD", 5),
+		ProviderBreakerCooldown:  mustDuration(get("PROVIDER_BREAKER_COOLDOWN", "30s")),
+		ProviderStrategy:         get("PROVIDER_STRATEGY", "all"),
+		ProviderFirstK:           GetEnvInt("PROVIDER_FIRST_K", 1),
+		ProviderHedgeDelay:       mustDuration(get("PROVIDER_HEDGE_DELAY", "3s")),
+		PromptTemplateDir:        get("PROMPT_TEMPLATE_DIR", ""),
+		PromptWeights:            split(get("PROMPT_WEIGHTS", "")),
+		PromptReloadInterval:     mustDuration(get("PROMPT_RELOAD_INTERVAL", "1m")),
+		ProviderStructured:       parseBool(get("PROVIDER_STRUCTURED", "true")),
+		ProviderStream:           parseBool(get("PROVIDER_STREAM", "true")),
+		OpenAIMaxTokens:          GetEnvInt("OPENAI_MAX_TOKENS", 256),
+		OpenAITemperature:        optFloat(get("OPENAI_TEMPERATURE", "0")),
+		OpenAIReasoningEffort:    get("OPENAI_REASONING_EFFORT", ""),
+		AnthropicMaxTokens:       GetEnvInt("ANTHROPIC_MAX_TOKENS", 512),
+		AnthropicTemperature:     optFloat(get("ANTHROPIC_TEMPERATURE", "none")),
+		AnthropicThinkingBudget:  GetEnvInt("ANTHROPIC_THINKING_BUDGET", 0),
+		GeminiMaxTokens:          GetEnvInt("GEMINI_MAX_TOKENS", 2048),
+		GeminiTemperature:        optFloat(get("GEMINI_TEMPERATURE", "0")),
+		GeminiThinkingBudget:     GetEnvInt("GEMINI_THINKING_BUDGET", 0),
+		MaxBodyLimit:             GetEnvInt("MAX_BODY_LIMIT", 8),
+		AllowedMaxFileSize:       GetEnvInt("ALLOWED_MAX_FILE_SIZE", 2),
+		AllowedFileExt:           GetEnvList("ALLOWED_FILE_EXT", []string{".jpg", ".jpeg", ".png", ".webp", ".gif", ".heic", ".heif"}),
+		HEIFDecoderCmd:           get("HEIF_DECODER_CMD", "heif-convert"),
+		PDFRenderCmd:             get("PDF_RENDER_CMD", "pdftoppm"),
+		PDFMaxPages:              GetEnvInt("PDF_MAX_PAGES", 10),
+		PDFRenderDPI:             GetEnvInt("PDF_RENDER_DPI", 150),
+		PDFMaxFileSize:           GetEnvInt("PDF_MAX_FILE_SIZE", 8),
 	}
+	c.OCRPrepSteps = split(get("OCR_PREP_STEPS_"+strings.ToUpper(c.OCREngine), get("OCR_PREP_STEPS", "")))
 	return c
 }
 
+// multipartOverhead is the room left in BodyLimit for the boundaries,
+// part headers and form fields around the uploaded files.
+const multipartOverhead = 1 << 20
+
+// BodyLimit is the request body limit in bytes: MaxBodyLimit, raised so that
+// the largest upload (QuizMaxImages images of AllowedMaxFileSize, or a PDF
+// of PDFMaxFileSize) gets past it to the upload validators with its
+// multipart framing.
+func (c *Config) BodyLimit() int {
+	const mb = 1024 * 1024
+	files := max(c.QuizMaxImages*c.AllowedMaxFileSize, c.PDFMaxFileSize) * mb
+	return max(c.MaxBodyLimit*mb, files+multipartOverhead)
+}
+
 func GetEnvInt(k string, d int) int {
 	if v := os.Getenv(k); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
@@ -118,6 +262,15 @@ func must(k string) string {
 func atoi(s string) int                   { i, _ := strconv.Atoi(s); return i }
 func parseBool(s string) bool             { b, _ := strconv.ParseBool(s); return b }
 func mustDuration(s string) time.Duration { d, _ := time.ParseDuration(s); return d }
+
+// optFloat parses s, or returns nil when it is not a number.
+func optFloat(s string) *float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
 func split(s string) []string {
 	if s == "" {
 		return nil
@@ -0,0 +1,2 @@
+ALTER TABLE quizzes ADD FULLTEXT INDEX ft_quizzes_ocr (ocr_text);
+ALTER TABLE answers ADD FULLTEXT INDEX ft_answers_text (answer_text, reason_text);
@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+  ADD COLUMN deleted_at TIMESTAMP NULL AFTER updated_at,
+  ADD KEY idx_deleted_at (deleted_at);
@@ -0,0 +1,4 @@
+-- quizzes now reference a storage object key instead of a local file path
+ALTER TABLE quizzes CHANGE image_path image_key VARCHAR(512) NOT NULL;
+UPDATE quizzes SET image_key = SUBSTRING(image_key, 11) WHERE image_key LIKE './storage/%';
+UPDATE quizzes SET image_key = SUBSTRING(image_key, 9) WHERE image_key LIKE 'storage/%';
@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+  ADD COLUMN source_quiz_id BIGINT UNSIGNED NULL AFTER user_id,
+  ADD KEY idx_user_hash (user_id, image_hash);
@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+  ADD COLUMN image_phash BIGINT UNSIGNED NULL AFTER image_hash,
+  ADD KEY idx_user_phash (user_id, image_phash);
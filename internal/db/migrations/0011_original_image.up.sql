@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+  ADD COLUMN original_key VARCHAR(512) NULL AFTER image_key,
+  ADD COLUMN crop_json JSON NULL AFTER original_key;
@@ -0,0 +1,23 @@
+-- ordered images of a quiz; position 0 mirrors the quizzes.image_* cover
+CREATE TABLE quiz_images (
+  id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+  quiz_id BIGINT UNSIGNED NOT NULL,
+  position TINYINT UNSIGNED NOT NULL,
+  image_key VARCHAR(512) NOT NULL,
+  original_key VARCHAR(512) NULL,
+  image_hash CHAR(64) NOT NULL,
+  image_width INT NULL,
+  image_height INT NULL,
+  ocr_text MEDIUMTEXT NULL,
+  ocr_status ENUM('pending','processing','done','error') NOT NULL DEFAULT 'pending',
+  ocr_error TEXT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE,
+  UNIQUE KEY uq_quiz_position (quiz_id, position)
+);
+
+INSERT INTO quiz_images (quiz_id, position, image_key, original_key, image_hash, image_width, image_height, ocr_text, ocr_status)
+SELECT id, 0, image_key, original_key, image_hash, image_width, image_height, ocr_text,
+       CASE WHEN ocr_text IS NOT NULL THEN 'done' WHEN status = 'error' THEN 'error' ELSE 'pending' END
+FROM quizzes;
@@ -0,0 +1,22 @@
+-- a PDF worksheet split into one quiz per question
+CREATE TABLE quiz_batches (
+  id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  source_key VARCHAR(512) NOT NULL,
+  filename VARCHAR(255) NULL,
+  page_count INT NULL,
+  question_count INT NULL,
+  preamble MEDIUMTEXT NULL,
+  status ENUM('processing','ready','error') NOT NULL DEFAULT 'processing',
+  error_text TEXT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  KEY idx_user (user_id)
+);
+
+ALTER TABLE quizzes
+  ADD COLUMN batch_id BIGINT UNSIGNED NULL AFTER source_quiz_id,
+  ADD COLUMN batch_position INT NULL AFTER batch_id,
+  ADD KEY idx_batch (batch_id, batch_position),
+  ADD CONSTRAINT fk_quiz_batch FOREIGN KEY (batch_id) REFERENCES quiz_batches(id);
@@ -0,0 +1,5 @@
+-- one answer per provider and question; 0 is the quiz as a whole
+ALTER TABLE answers
+  ADD COLUMN question_no INT NOT NULL DEFAULT 0 AFTER source,
+  ADD UNIQUE KEY uq_quiz_source_question (quiz_id, source, question_no),
+  DROP INDEX uq_quiz_source;
@@ -0,0 +1 @@
+ALTER TABLE quizzes ADD COLUMN strategy VARCHAR(16) NULL AFTER ocr_lang;
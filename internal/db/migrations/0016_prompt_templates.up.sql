@@ -0,0 +1,14 @@
+CREATE TABLE prompt_templates (
+  id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+  kind ENUM('single','multi') NOT NULL,
+  version VARCHAR(64) NOT NULL,
+  body MEDIUMTEXT NOT NULL,
+  weight INT NOT NULL DEFAULT 1,
+  active TINYINT(1) NOT NULL DEFAULT 1,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_kind_version (kind, version)
+);
+
+ALTER TABLE answers ADD COLUMN prompt_version VARCHAR(64) NULL AFTER token_usage_json;
+
+ALTER TABLE quizzes ADD COLUMN subject VARCHAR(64) NULL AFTER ocr_lang;
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN answer_lang VARCHAR(8) NULL;
+
+ALTER TABLE prompt_templates
+  ADD COLUMN lang VARCHAR(8) NOT NULL DEFAULT '' AFTER kind,
+  DROP INDEX uq_kind_version,
+  ADD UNIQUE KEY uq_kind_lang_version (kind, lang, version);
@@ -0,0 +1 @@
+ALTER TABLE answers ADD COLUMN parse_mode VARCHAR(16) NULL AFTER prompt_version;
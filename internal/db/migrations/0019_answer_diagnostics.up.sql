@@ -0,0 +1,4 @@
+ALTER TABLE answers
+  ADD COLUMN parse_confidence DECIMAL(4,3) NULL AFTER parse_mode,
+  ADD COLUMN parse_warnings_json JSON NULL AFTER parse_confidence,
+  ADD COLUMN raw_text MEDIUMTEXT NULL AFTER parse_warnings_json;
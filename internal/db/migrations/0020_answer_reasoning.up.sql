@@ -0,0 +1,2 @@
+ALTER TABLE answers
+  ADD COLUMN reasoning_text MEDIUMTEXT NULL AFTER raw_text;
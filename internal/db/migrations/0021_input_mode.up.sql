@@ -0,0 +1,8 @@
+ALTER TABLE quizzes
+  ADD COLUMN input_mode VARCHAR(16) NULL AFTER strategy;
+
+ALTER TABLE answers
+  ADD COLUMN input_mode VARCHAR(16) NULL AFTER prompt_version;
+
+ALTER TABLE quiz_images
+  MODIFY ocr_status ENUM('pending','processing','done','error','skipped') NOT NULL DEFAULT 'pending';
@@ -0,0 +1,6 @@
+ALTER TABLE quizzes
+  ADD COLUMN ocr_mode VARCHAR(16) NULL AFTER input_mode,
+  ADD COLUMN ocr_latex MEDIUMTEXT NULL AFTER ocr_text;
+
+ALTER TABLE quiz_images
+  ADD COLUMN ocr_latex MEDIUMTEXT NULL AFTER ocr_text;
@@ -0,0 +1,89 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // first frame only
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// HEIFDecoderCmd converts HEIC/HEIF uploads, invoked as `cmd <in> <out.jpg>`
+// (libheif's heif-convert by default). Empty disables HEIF support.
+var HEIFDecoderCmd = "heif-convert"
+
+const heifDecodeTimeout = 30 * time.Second
+
+var (
+	ErrUnsupportedFormat = errors.New("img: unsupported image format")
+	// ErrCorrupt is returned for uploads of a known format that do not
+	// decode, e.g. truncated files.
+	ErrCorrupt = errors.New("img: corrupt image")
+)
+
+// Decode decodes a JPEG, PNG, GIF (first frame), WebP or HEIF upload with
+// its EXIF orientation applied.
+func Decode(src []byte) (image.Image, error) {
+	if IsHEIF(src) {
+		jpg, err := convertHEIF(src)
+		if err != nil {
+			return nil, err
+		}
+		src = jpg
+	}
+	im, err := imaging.Decode(bytes.NewReader(src), imaging.AutoOrientation(true))
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		return nil, ErrUnsupportedFormat
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return im, nil
+}
+
+// IsHEIF reports whether head starts with an ISO-BMFF ftyp box of a
+// HEIC/HEIF brand.
+func IsHEIF(head []byte) bool {
+	if len(head) < 12 || !bytes.Equal(head[4:8], []byte("ftyp")) {
+		return false
+	}
+	switch string(head[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+func convertHEIF(src []byte) ([]byte, error) {
+	if HEIFDecoderCmd == "" {
+		return nil, ErrUnsupportedFormat
+	}
+	dir, err := os.MkdirTemp("", "heif-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in, out := filepath.Join(dir, "in.heic"), filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(in, src, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), heifDecodeTimeout)
+	defer cancel()
+	if msg, err := exec.CommandContext(ctx, HEIFDecoderCmd, in, out).CombinedOutput(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, fmt.Errorf("%w: heif decode: %v: %s", ErrCorrupt, err, bytes.TrimSpace(msg))
+	}
+	return os.ReadFile(out)
+}
@@ -0,0 +1,88 @@
+package img
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readSample(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "formats", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// withHEIFDecoder runs the test with cmd as HEIFDecoderCmd.
+func withHEIFDecoder(t *testing.T, cmd string) {
+	t.Helper()
+	prev := HEIFDecoderCmd
+	HEIFDecoderCmd = cmd
+	t.Cleanup(func() { HEIFDecoderCmd = prev })
+}
+
+func TestDecodeFormats(t *testing.T) {
+	fake, err := filepath.Abs(filepath.Join("testdata", "formats", "heif-convert.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withHEIFDecoder(t, fake)
+
+	tests := []struct {
+		file string
+		w, h int
+	}{
+		{"sample.jpg", 16, 12},
+		{"sample.png", 16, 12},
+		{"sample.gif", 16, 12},
+		{"sample-lossless.webp", 1, 1},
+		{"sample-lossy.webp", 1, 1},
+		{"sample.heic", 16, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			im, err := Decode(readSample(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := im.Bounds().Size(); s.X != tt.w || s.Y != tt.h {
+				t.Errorf("size %v, want %dx%d", s, tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name, file, heifCmd string
+		want                error
+	}{
+		{"unknown format", "notimage.txt", "heif-convert", ErrUnsupportedFormat},
+		{"truncated jpeg", "corrupt.jpg", "heif-convert", ErrCorrupt},
+		{"heif disabled", "sample.heic", "", ErrUnsupportedFormat},
+		{"heif decoder missing", "sample.heic", "lemme-no-such-heif-convert", ErrUnsupportedFormat},
+		{"heif decoder fails", "sample.heic", "false", ErrCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHEIFDecoder(t, tt.heifCmd)
+			if _, err := Decode(readSample(t, tt.file)); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHEIF(t *testing.T) {
+	if !IsHEIF(readSample(t, "sample.heic")) {
+		t.Error("sample.heic not recognised")
+	}
+	for _, f := range []string{"sample.jpg", "sample-lossy.webp", "notimage.txt"} {
+		if IsHEIF(readSample(t, f)) {
+			t.Errorf("%s taken for HEIF", f)
+		}
+	}
+}
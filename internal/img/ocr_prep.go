@@ -5,7 +5,6 @@ import (
 	"image"
 	"image/color"
 	"image/jpeg"
-	"os"
 
 	"github.com/disintegration/imaging"
 )
@@ -15,9 +14,9 @@ type Prepared struct {
 	MIME  string
 }
 
-// PrepareForOCR: resize → grayscale (optional) → low quality JPEG (token saving)
-func PrepareForOCR(path string, maxW, quality int, grayscale bool) (Prepared, error) {
-	src, err := imaging.Open(path, imaging.AutoOrientation(true))
+// PrepareForOCR: resize → grayscale (optional) → steps → low quality JPEG (token saving)
+func PrepareForOCR(data []byte, maxW, quality int, grayscale bool, steps ...Step) (Prepared, error) {
+	src, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
 	if err != nil {
 		return Prepared{}, err
 	}
@@ -32,8 +31,10 @@ func PrepareForOCR(path string, maxW, quality int, grayscale bool) (Prepared, er
 		// or: src = imaging.Grayscale(src)
 	}
 
-	// we can try some blur to reduce noise
-	// src = imaging.Blur(src, 0.6)
+	// deskew, binarization, cropping... as configured (see ParseSteps)
+	for _, step := range steps {
+		src = step(src)
+	}
 
 	var buf bytes.Buffer
 	if err := jpeg.Encode(&buf, forceOpaque(src), &jpeg.Options{Quality: clamp(quality, 40, 85)}); err != nil {
@@ -69,9 +70,3 @@ func clamp(v, lo, hi int) int {
 	}
 	return v
 }
-
-// helper if you need []byte from path (e.g. fallback)
-func MustRead(path string) []byte {
-	b, _ := os.ReadFile(path)
-	return b
-}
@@ -0,0 +1,80 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PDFRenderCmd renders PDF pages to PNG; it is invoked like poppler's
+// pdftoppm (`cmd -png -r <dpi> -l <last> <in> <prefix>`). Empty disables
+// PDF uploads.
+var PDFRenderCmd = "pdftoppm"
+
+const pdfRenderTimeout = 2 * time.Minute
+
+var ErrPDFUnsupported = errors.New("img: pdf rendering not available")
+
+// IsPDF reports whether data starts with the PDF magic.
+func IsPDF(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("%PDF-"))
+}
+
+// RenderPDF renders up to maxPages pages of a PDF as PNG images in page order.
+func RenderPDF(ctx context.Context, data []byte, maxPages, dpi int) ([][]byte, error) {
+	if PDFRenderCmd == "" {
+		return nil, ErrPDFUnsupported
+	}
+	dir, err := os.MkdirTemp("", "pdf-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.pdf")
+	if err := os.WriteFile(in, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pdfRenderTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, PDFRenderCmd,
+		"-png", "-r", strconv.Itoa(dpi), "-l", strconv.Itoa(maxPages), in, filepath.Join(dir, "page"))
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, ErrPDFUnsupported
+		}
+		return nil, fmt.Errorf("img: pdf render: %w: %s", err, bytes.TrimSpace(msg))
+	}
+
+	// pdftoppm zero-pads page numbers to the page count width
+	files, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if len(files[i]) != len(files[j]) {
+			return len(files[i]) < len(files[j])
+		}
+		return files[i] < files[j]
+	})
+	pages := make([][]byte, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, b)
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("img: pdf has no pages")
+	}
+	return pages, nil
+}
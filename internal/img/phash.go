@@ -0,0 +1,61 @@
+package img
+
+import (
+	"image"
+	"math/bits"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// DHash computes a 64-bit difference hash: the image is shrunk to 9x8 gray
+// pixels and each bit records whether a pixel is brighter than its right
+// neighbour. Re-encodes, rescales and small crops keep most bits stable.
+func DHash(im image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(im, 9, 8, imaging.Box))
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			l := small.Pix[small.PixOffset(x, y)]
+			r := small.Pix[small.PixOffset(x+1, y)]
+			h <<= 1
+			if l > r {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// HammingDistance counts differing bits between two hashes (0 = identical).
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+type HashedImage struct {
+	ID   int64
+	Hash uint64
+}
+
+type HashMatch struct {
+	ID       int64
+	Distance int
+}
+
+// NearestHashes returns the candidates within maxDist of target, closest
+// first (ties by newest ID).
+func NearestHashes(target uint64, candidates []HashedImage, maxDist int) []HashMatch {
+	var out []HashMatch
+	for _, c := range candidates {
+		if d := HammingDistance(target, c.Hash); d <= maxDist {
+			out = append(out, HashMatch{ID: c.ID, Distance: d})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
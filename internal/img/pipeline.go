@@ -0,0 +1,407 @@
+package img
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Step is one stage of the OCR preprocessing pipeline.
+type Step func(image.Image) image.Image
+
+// namedSteps are the steps selectable through OCR_PREP_STEPS.
+var namedSteps = map[string]Step{
+	"grayscale": func(im image.Image) image.Image { return toGray(im) },
+	"contrast":  ContrastStretch,
+	"denoise":   Denoise,
+	"deskew":    Deskew,
+	"otsu":      Binarize,
+	"adaptive":  AdaptiveBinarize,
+	"trim":      TrimBorders,
+	"autocrop":  AutoCrop,
+	"blur":      func(im image.Image) image.Image { return imaging.Blur(im, 0.6) },
+}
+
+// ParseSteps resolves step names (e.g. "deskew,contrast,adaptive") in order.
+func ParseSteps(names []string) ([]Step, error) {
+	var out []Step
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" {
+			continue
+		}
+		s, ok := namedSteps[n]
+		if !ok {
+			return nil, fmt.Errorf("img: unknown preprocessing step %q", n)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// ContrastStretch maps the 1st..99th luminance percentile onto 0..255.
+func ContrastStretch(im image.Image) image.Image {
+	g := toGray(im)
+	hist := histogram(g)
+	total := len(g.Pix)
+	lo, hi := percentile(hist, total, 0.01), percentile(hist, total, 0.99)
+	if hi <= lo {
+		return g
+	}
+	scale := 255 / float64(hi-lo)
+	for i, v := range g.Pix {
+		switch {
+		case int(v) <= lo:
+			g.Pix[i] = 0
+		case int(v) >= hi:
+			g.Pix[i] = 255
+		default:
+			g.Pix[i] = uint8(float64(int(v)-lo)*scale + 0.5)
+		}
+	}
+	return g
+}
+
+// Denoise applies a 3x3 median filter, which removes speckles from phone
+// photos while keeping glyph edges sharp.
+func Denoise(im image.Image) image.Image {
+	src := toGray(im)
+	b := src.Bounds()
+	dst := image.NewGray(b)
+	var win [9]uint8
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					px, py := clamp(x+dx, b.Min.X, b.Max.X-1), clamp(y+dy, b.Min.Y, b.Max.Y-1)
+					win[n] = src.Pix[src.PixOffset(px, py)]
+					n++
+				}
+			}
+			// insertion sort; far cheaper than sort.Slice for 9 values
+			for i := 1; i < len(win); i++ {
+				for j := i; j > 0 && win[j] < win[j-1]; j-- {
+					win[j], win[j-1] = win[j-1], win[j]
+				}
+			}
+			dst.Pix[dst.PixOffset(x, y)] = win[4]
+		}
+	}
+	return dst
+}
+
+// Binarize turns the image black and white at the global Otsu threshold;
+// see AdaptiveBinarize for unevenly lit images.
+func Binarize(im image.Image) image.Image {
+	g := toGray(im)
+	t := otsuThreshold(histogram(g), len(g.Pix))
+	for i, v := range g.Pix {
+		if int(v) > t {
+			g.Pix[i] = 255
+		} else {
+			g.Pix[i] = 0
+		}
+	}
+	return g
+}
+
+const (
+	adaptiveWindowDiv = 16
+	adaptiveMinWindow = 15
+	adaptiveOffset    = 0.15
+)
+
+// AdaptiveBinarize turns the image black and white with a local threshold
+// (Bradley–Roth): a pixel is ink when it is darker than the mean of the
+// window around it by adaptiveOffset. Unlike Binarize it copes with uneven
+// lighting and shadows across phone photos.
+func AdaptiveBinarize(im image.Image) image.Image {
+	g := toGray(im)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	half := max(max(w, h)/adaptiveWindowDiv, adaptiveMinWindow) / 2
+
+	// integral image with a zero first row and column
+	sum := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)])
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+
+	out := image.NewGray(b)
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-half, 0), min(y+half+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-half, 0), min(x+half+1, w)
+			area := int64((x1 - x0) * (y1 - y0))
+			total := sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+			v := g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)]
+			if float64(v)*float64(area) <= float64(total)*(1-adaptiveOffset) {
+				out.Pix[out.PixOffset(b.Min.X+x, b.Min.Y+y)] = 0
+			} else {
+				out.Pix[out.PixOffset(b.Min.X+x, b.Min.Y+y)] = 255
+			}
+		}
+	}
+	return out
+}
+
+const (
+	deskewMaxAngle = 15.0
+	deskewStep     = 0.5
+	deskewMinAngle = 0.3
+	deskewSampleW  = 800
+)
+
+// Deskew estimates the text skew from horizontal projection profiles and
+// rotates the image so lines become horizontal.
+func Deskew(im image.Image) image.Image {
+	sample := im
+	if sample.Bounds().Dx() > deskewSampleW {
+		sample = imaging.Resize(sample, deskewSampleW, 0, imaging.Box)
+	}
+	// a local threshold keeps shadows from passing for lines of ink
+	g := AdaptiveBinarize(sample).(*image.Gray)
+
+	b := g.Bounds()
+	var ink []image.Point
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if g.Pix[g.PixOffset(x, y)] == 0 {
+				ink = append(ink, image.Pt(x-b.Min.X, y-b.Min.Y))
+			}
+		}
+	}
+	// nothing to align on, or the page is mostly dark
+	if len(ink) == 0 || len(ink) > len(g.Pix)/2 {
+		return im
+	}
+
+	h := b.Dy() + b.Dx()
+	best, bestScore := 0.0, -1.0
+	bins := make([]int, 2*h+1)
+	for a := -deskewMaxAngle; a <= deskewMaxAngle; a += deskewStep {
+		sin, cos := math.Sincos(a * math.Pi / 180)
+		clear(bins)
+		for _, p := range ink {
+			y := int(float64(p.Y)*cos-float64(p.X)*sin) + h
+			if y >= 0 && y < len(bins) {
+				bins[y]++
+			}
+		}
+		score := 0.0
+		for _, n := range bins {
+			score += float64(n) * float64(n)
+		}
+		if score > bestScore {
+			best, bestScore = a, score
+		}
+	}
+
+	if math.Abs(best) < deskewMinAngle {
+		return im
+	}
+	// lines sloping down to the right need a counter-clockwise turn
+	return imaging.Rotate(im, best, color.White)
+}
+
+const (
+	trimTolerance = 32
+	trimMaxNoise  = 0.005
+)
+
+// TrimBorders removes uniform margins whose colour matches the corners,
+// e.g. scanner edges or the dark frame around a photographed screen.
+func TrimBorders(im image.Image) image.Image {
+	g := toGray(im)
+	b := g.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return im
+	}
+	corners := []uint8{
+		g.GrayAt(b.Min.X, b.Min.Y).Y, g.GrayAt(b.Max.X-1, b.Min.Y).Y,
+		g.GrayAt(b.Min.X, b.Max.Y-1).Y, g.GrayAt(b.Max.X-1, b.Max.Y-1).Y,
+	}
+	sort.Slice(corners, func(i, j int) bool { return corners[i] < corners[j] })
+	bg := int(corners[1]+corners[2]) / 2
+
+	off := func(v uint8) bool { return abs(int(v)-bg) > trimTolerance }
+	rowIsBorder := func(y int) bool {
+		n := 0
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if off(g.GrayAt(x, y).Y) {
+				n++
+			}
+		}
+		return float64(n) <= trimMaxNoise*float64(b.Dx())
+	}
+	colIsBorder := func(x, y0, y1 int) bool {
+		n := 0
+		for y := y0; y < y1; y++ {
+			if off(g.GrayAt(x, y).Y) {
+				n++
+			}
+		}
+		return float64(n) <= trimMaxNoise*float64(y1-y0)
+	}
+
+	top, bottom := b.Min.Y, b.Max.Y
+	for top < bottom && rowIsBorder(top) {
+		top++
+	}
+	for bottom > top && rowIsBorder(bottom-1) {
+		bottom--
+	}
+	left, right := b.Min.X, b.Max.X
+	for left < right && colIsBorder(left, top, bottom) {
+		left++
+	}
+	for right > left && colIsBorder(right-1, top, bottom) {
+		right--
+	}
+
+	r := image.Rect(left, top, right, bottom)
+	if r.Empty() || r == b {
+		return im
+	}
+	return imaging.Crop(im, r)
+}
+
+const (
+	autoCropPad      = 12
+	autoCropMinInk   = 2
+	autoCropMinShare = 0.2
+)
+
+// AutoCrop crops to the bounding box of the text (pixels darker than the
+// Otsu threshold) plus a small margin. Isolated specks are ignored.
+func AutoCrop(im image.Image) image.Image {
+	g := toGray(im)
+	b := g.Bounds()
+	t := otsuThreshold(histogram(g), len(g.Pix))
+
+	rows := make([]int, b.Dy())
+	cols := make([]int, b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if int(g.Pix[g.PixOffset(x, y)]) <= t {
+				rows[y-b.Min.Y]++
+				cols[x-b.Min.X]++
+			}
+		}
+	}
+	y0, y1 := span(rows, autoCropMinInk)
+	x0, x1 := span(cols, autoCropMinInk)
+	if y0 < 0 || x0 < 0 {
+		return im
+	}
+
+	r := image.Rect(
+		b.Min.X+max(x0-autoCropPad, 0), b.Min.Y+max(y0-autoCropPad, 0),
+		b.Min.X+min(x1+autoCropPad+1, b.Dx()), b.Min.Y+min(y1+autoCropPad+1, b.Dy()),
+	)
+	// refuse crops that would throw away almost everything; likely noise
+	if r.Dx()*r.Dy() < int(autoCropMinShare*float64(b.Dx()*b.Dy())) || r == b {
+		return im
+	}
+	return imaging.Crop(im, r)
+}
+
+func toGray(im image.Image) *image.Gray {
+	b := im.Bounds()
+	out := image.NewGray(b)
+	if g, ok := im.(*image.Gray); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			copy(out.Pix[out.PixOffset(b.Min.X, y):out.PixOffset(b.Max.X, y)], g.Pix[g.PixOffset(b.Min.X, y):])
+		}
+		return out
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, gg, bl, a := im.At(x, y).RGBA()
+			if a == 0 {
+				out.Pix[out.PixOffset(x, y)] = 255
+				continue
+			}
+			// Rec. 601 luma on 16-bit channels
+			out.Pix[out.PixOffset(x, y)] = uint8((19595*r + 38470*gg + 7471*bl + 1<<15) >> 24)
+		}
+	}
+	return out
+}
+
+func histogram(g *image.Gray) [256]int {
+	var h [256]int
+	for _, v := range g.Pix {
+		h[v]++
+	}
+	return h
+}
+
+func percentile(hist [256]int, total int, p float64) int {
+	want := int(p * float64(total))
+	sum := 0
+	for v, n := range hist {
+		sum += n
+		if sum > want {
+			return v
+		}
+	}
+	return 255
+}
+
+// otsuThreshold picks the level that maximizes between-class variance.
+func otsuThreshold(hist [256]int, total int) int {
+	var sumAll float64
+	for v, n := range hist {
+		sumAll += float64(v * n)
+	}
+	var sumB, wB float64
+	best, bestVar := 127, -1.0
+	for t := 0; t < 256; t++ {
+		wB += float64(hist[t])
+		if wB == 0 {
+			continue
+		}
+		wF := float64(total) - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		mB, mF := sumB/wB, (sumAll-sumB)/wF
+		if v := wB * wF * (mB - mF) * (mB - mF); v > bestVar {
+			best, bestVar = t, v
+		}
+	}
+	return best
+}
+
+// span returns the first and last index whose count reaches minCount.
+func span(counts []int, minCount int) (int, int) {
+	first, last := -1, -1
+	for i, n := range counts {
+		if n >= minCount {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
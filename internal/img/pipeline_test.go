@@ -0,0 +1,279 @@
+package img
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/page.png and the golden images")
+
+// pagePath is a synthetic phone photo of a quiz: lines of glyphs tilted by
+// pageSkew degrees, lit from the left, with speckles and a dark frame
+// around the paper.
+const (
+	pagePath = "testdata/page.png"
+	pageSkew = 4.0
+)
+
+// renderPage draws the image stored at pagePath.
+func renderPage() image.Image {
+	const w, h = 320, 220
+	paper := image.NewGray(image.Rect(0, 0, w, h))
+	light := func(x int) float64 { return 235 - 125*float64(x)/w }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			paper.SetGray(x, y, color.Gray{uint8(light(x))})
+		}
+	}
+	rnd := rand.New(rand.NewSource(34))
+	for line := 0; line < 6; line++ {
+		y0 := 50 + line*22
+		for x := 60; x < 270; {
+			gw := 4 + rnd.Intn(7)
+			for y := y0; y < y0+10; y++ {
+				for xx := x; xx < x+gw && xx < w; xx++ {
+					paper.SetGray(xx, y, color.Gray{uint8(light(xx) - 80)})
+				}
+			}
+			x += gw + 3 + rnd.Intn(4)
+			if rnd.Intn(6) == 0 {
+				x += 10
+			}
+		}
+	}
+	tilted := imaging.Rotate(paper, -pageSkew, color.Gray{uint8(light(w))})
+
+	out := toGray(tilted)
+	b := out.Bounds()
+	for i := 0; i < len(out.Pix)/150; i++ {
+		x, y := b.Min.X+rnd.Intn(b.Dx()), b.Min.Y+rnd.Intn(b.Dy())
+		out.Pix[out.PixOffset(x, y)] = uint8(rnd.Intn(2) * 255)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if x-b.Min.X < 14 || b.Max.X-x <= 14 || y-b.Min.Y < 10 || b.Max.Y-y <= 10 {
+				out.Pix[out.PixOffset(x, y)] = 20
+			}
+		}
+	}
+	return out
+}
+
+func loadPNG(t *testing.T, path string) *image.Gray {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	defer f.Close()
+	im, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toGray(im)
+}
+
+func savePNG(t *testing.T, path string, im image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, im); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func page(t *testing.T) *image.Gray {
+	t.Helper()
+	if *update {
+		savePNG(t, pagePath, renderPage())
+	}
+	return loadPNG(t, pagePath)
+}
+
+// TestStepsGolden runs every OCR_PREP_STEPS step on the page and compares
+// the result with testdata/golden/<step>.png. Rotation and blur use floats,
+// so a few pixels may round differently across platforms.
+func TestStepsGolden(t *testing.T) {
+	src := page(t)
+	for name, step := range namedSteps {
+		t.Run(name, func(t *testing.T) {
+			got := toGray(step(src))
+			path := filepath.Join("testdata", "golden", name+".png")
+			if *update {
+				savePNG(t, path, got)
+			}
+			want := loadPNG(t, path)
+			if got.Bounds().Size() != want.Bounds().Size() {
+				t.Fatalf("size %v, golden %v", got.Bounds().Size(), want.Bounds().Size())
+			}
+			if d := grayDiff(got, want); d > 0.005 {
+				t.Errorf("%.2f%% of pixels differ from %s", d*100, path)
+			}
+		})
+	}
+}
+
+// grayDiff is the share of pixels differing by more than a rounding step.
+func grayDiff(a, b *image.Gray) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	n := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			if abs(int(a.GrayAt(ab.Min.X+x, ab.Min.Y+y).Y)-int(b.GrayAt(bb.Min.X+x, bb.Min.Y+y).Y)) > 2 {
+				n++
+			}
+		}
+	}
+	return float64(n) / float64(ab.Dx()*ab.Dy())
+}
+
+func TestParseSteps(t *testing.T) {
+	steps, err := ParseSteps([]string{"Deskew", " contrast ", "", "adaptive"})
+	if err != nil || len(steps) != 3 {
+		t.Fatalf("ParseSteps = %d steps, %v", len(steps), err)
+	}
+	if _, err := ParseSteps([]string{"sharpen"}); err == nil {
+		t.Error("unknown step accepted")
+	}
+}
+
+// inkShare is the share of dark pixels of im inside r.
+func inkShare(im image.Image, r image.Rectangle) float64 {
+	g := toGray(im)
+	r = r.Intersect(g.Bounds())
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if g.GrayAt(x, y).Y < 128 {
+				n++
+			}
+		}
+	}
+	return float64(n) / float64(r.Dx()*r.Dy())
+}
+
+func TestAdaptiveBinarizeUnevenLight(t *testing.T) {
+	src := page(t)
+	b := src.Bounds()
+	lit := image.Rect(b.Min.X+70, b.Min.Y+60, b.Min.X+140, b.Min.Y+170)
+	shaded := image.Rect(b.Min.X+180, b.Min.Y+60, b.Min.X+280, b.Min.Y+170)
+	paper := image.Rect(b.Max.X-40, b.Min.Y+20, b.Max.X-20, b.Min.Y+40)
+
+	adaptive := AdaptiveBinarize(src)
+	for name, r := range map[string]image.Rectangle{"lit text": lit, "shaded text": shaded} {
+		if got := inkShare(adaptive, r); got < 0.15 {
+			t.Errorf("adaptive: %s is %.0f%% ink", name, got*100)
+		}
+	}
+	if got := inkShare(adaptive, paper); got > 0.02 {
+		t.Errorf("adaptive: blank paper is %.0f%% ink", got*100)
+	}
+	// the global threshold loses the text on the lit side
+	if got := inkShare(Binarize(src), lit); got > 0.02 {
+		t.Errorf("otsu: lit text is %.0f%% ink", got*100)
+	}
+}
+
+// skewOf estimates the skew the same way Deskew does, in degrees.
+func skewOf(im image.Image) float64 {
+	g := toGray(im)
+	b := g.Bounds()
+	var ink []image.Point
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if g.GrayAt(x, y).Y < 100 {
+				ink = append(ink, image.Pt(x, y))
+			}
+		}
+	}
+	best, bestScore := 0.0, -1.0
+	for a := -deskewMaxAngle; a <= deskewMaxAngle; a += deskewStep {
+		sin, cos := math.Sincos(a * math.Pi / 180)
+		bins := map[int]int{}
+		for _, p := range ink {
+			bins[int(float64(p.Y)*cos-float64(p.X)*sin)]++
+		}
+		score := 0.0
+		for _, n := range bins {
+			score += float64(n) * float64(n)
+		}
+		if score > bestScore {
+			best, bestScore = a, score
+		}
+	}
+	return best
+}
+
+func TestDeskewStraightensLines(t *testing.T) {
+	src := TrimBorders(page(t))
+	if before, after := skewOf(src), skewOf(Deskew(src)); math.Abs(before) < 2 || math.Abs(after) > 0.5 {
+		t.Errorf("skew %.1f° before, %.1f° after deskew", before, after)
+	}
+}
+
+func TestTrimBorders(t *testing.T) {
+	src := page(t)
+	got, want := TrimBorders(src).Bounds().Size(), src.Bounds().Size().Sub(image.Pt(28, 20))
+	if got != want {
+		t.Errorf("TrimBorders: size %v, want %v without the frame", got, want)
+	}
+}
+
+func TestAutoCrop(t *testing.T) {
+	im := image.NewGray(image.Rect(0, 0, 200, 150))
+	for i := range im.Pix {
+		im.Pix[i] = 250
+	}
+	// five lines of text, the last one on rows 100..104
+	for y := 60; y < 110; y++ {
+		for x := 30; x < 170; x++ {
+			if (y/5)%2 == 0 {
+				im.SetGray(x, y, color.Gray{10})
+			}
+		}
+	}
+	// a lone speck far from the text
+	im.SetGray(190, 5, color.Gray{0})
+
+	got := AutoCrop(im).Bounds()
+	want := image.Rect(30-autoCropPad, 60-autoCropPad, 170+autoCropPad, 105+autoCropPad)
+	if got.Size() != want.Size() {
+		t.Errorf("AutoCrop: size %v, want %v", got.Size(), want.Size())
+	}
+}
+
+func TestDenoiseRemovesSpeckles(t *testing.T) {
+	im := image.NewGray(image.Rect(0, 0, 9, 9))
+	for i := range im.Pix {
+		im.Pix[i] = 255
+	}
+	im.SetGray(4, 4, color.Gray{0})
+	if got := toGray(Denoise(im)).GrayAt(4, 4).Y; got != 255 {
+		t.Errorf("speckle left at %d", got)
+	}
+}
+
+func TestContrastStretch(t *testing.T) {
+	got := toGray(ContrastStretch(page(t))).Pix
+	sorted := append([]uint8{}, got...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if lo, hi := sorted[len(sorted)/50], sorted[len(sorted)-len(sorted)/50]; lo > 5 || hi < 250 {
+		t.Errorf("range %d..%d after stretch", lo, hi)
+	}
+}
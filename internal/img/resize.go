@@ -1,26 +1,34 @@
 package img
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"image"
-	"os"
-	"path/filepath"
 
 	"github.com/disintegration/imaging"
+
+	"github.com/emandor/lemme_service/internal/storage"
 )
 
 type SaveResult struct {
-	Path          string
+	Key           string
 	Hash          string
+	PHash         uint64
 	Width, Height int
 }
 
-func SaveResizedJPEG(srcPath, dstDir string, maxW int) (SaveResult, error) {
-	im, err := imaging.Open(srcPath)
+// SaveResizedJPEG decodes src (any format Decode accepts), applies t,
+// shrinks it to maxW and stores it as JPEG under key.
+func SaveResizedJPEG(ctx context.Context, blob storage.Blob, key string, src []byte, maxW int, t Transform) (SaveResult, error) {
+	im, err := Decode(src)
 	if err != nil {
 		return SaveResult{}, err
 	}
+	if im, err = t.Apply(im); err != nil {
+		return SaveResult{}, err
+	}
 	w := im.Bounds().Dx()
 	var out image.Image
 	if w > maxW {
@@ -28,15 +36,21 @@ func SaveResizedJPEG(srcPath, dstDir string, maxW int) (SaveResult, error) {
 	} else {
 		out = im
 	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, out, imaging.JPEG, imaging.JPEGQuality(85)); err != nil {
 		return SaveResult{}, err
 	}
-	tmp := filepath.Join(dstDir, filepath.Base(srcPath)+".jpg")
-	if err := imaging.Save(out, tmp, imaging.JPEGQuality(85)); err != nil {
+	if err := blob.Put(ctx, key, buf.Bytes(), "image/jpeg"); err != nil {
 		return SaveResult{}, err
 	}
 
-	b, _ := os.ReadFile(tmp)
-	h := sha256.Sum256(b)
-	return SaveResult{Path: tmp, Hash: hex.EncodeToString(h[:]), Width: out.Bounds().Dx(), Height: out.Bounds().Dy()}, nil
+	h := sha256.Sum256(buf.Bytes())
+	return SaveResult{
+		Key:    key,
+		Hash:   hex.EncodeToString(h[:]),
+		PHash:  DHash(out),
+		Width:  out.Bounds().Dx(),
+		Height: out.Bounds().Dy(),
+	}, nil
 }
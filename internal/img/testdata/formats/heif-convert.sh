@@ -0,0 +1,4 @@
+#!/bin/sh
+# Stands in for libheif's heif-convert in tests: sample.heic holds no real
+# image, so its "conversion" is sample.jpg.
+cp "$(dirname "$0")/sample.jpg" "$2"
@@ -0,0 +1,62 @@
+package img
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// Transform is a client-supplied rotation and crop. Rotation is applied
+// first, clockwise in degrees (as CSS and most croppers do); Crop is in
+// pixels of the rotated image. A zero Transform leaves the image as is.
+type Transform struct {
+	Rotate float64
+	Crop   image.Rectangle
+}
+
+var ErrCropOutside = errors.New("img: crop rectangle outside image")
+
+func (t Transform) IsZero() bool {
+	return t.Rotate == 0 && t.Crop.Empty()
+}
+
+func (t Transform) Apply(im image.Image) (image.Image, error) {
+	switch r := normAngle(t.Rotate); r {
+	case 0:
+	case 90:
+		im = imaging.Rotate270(im)
+	case 180:
+		im = imaging.Rotate180(im)
+	case 270:
+		im = imaging.Rotate90(im)
+	default:
+		// imaging rotates counter-clockwise
+		im = imaging.Rotate(im, -r, color.White)
+	}
+
+	if t.Crop.Empty() {
+		return im, nil
+	}
+	b := im.Bounds()
+	rect := t.Crop.Add(b.Min)
+	if !rect.In(b) {
+		// tolerate rounding from scaled client previews, reject real misses
+		rect = rect.Intersect(b)
+		if rect.Dx() < t.Crop.Dx()/2 || rect.Dy() < t.Crop.Dy()/2 {
+			return nil, ErrCropOutside
+		}
+	}
+	return imaging.Crop(im, rect), nil
+}
+
+func normAngle(a float64) float64 {
+	for a < 0 {
+		a += 360
+	}
+	for a >= 360 {
+		a -= 360
+	}
+	return a
+}
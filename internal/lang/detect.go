@@ -0,0 +1,138 @@
+// Package lang detects the language of OCR text offline, from stopword
+// and affix frequencies. Codes follow Tesseract ("eng", "ind") so they
+// line up with OCR_LANG.
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	English    = "eng"
+	Indonesian = "ind"
+)
+
+// minWords is the least number of words a guess is made on.
+const minWords = 3
+
+// minScore is the least share of recognised words for a guess.
+const minScore = 0.08
+
+type profile struct {
+	name      string
+	stopwords map[string]bool
+	// affixes count half a stopword each; they catch running text with
+	// few function words, e.g. question stems.
+	prefixes, suffixes []string
+}
+
+var profiles = map[string]profile{
+	English: {
+		name: "English",
+		stopwords: set(`the an of and or to in on at is are was were be been which what who whom whose
+			when where why how this that these those it its for with from by as not no if then than
+			do does did can could will would should may might must has have had following correct true false`),
+		suffixes: []string{"tion", "ness", "ing", "ly"},
+	},
+	Indonesian: {
+		name: "Indonesian",
+		stopwords: set(`yang dan di ke dari ini itu adalah dengan untuk pada dalam tidak akan atau juga
+			oleh sebagai karena jika maka apa siapa mengapa bagaimana kapan dimana berapa manakah
+			berikut merupakan tersebut ada bisa dapat harus sudah belum telah sangat lebih paling
+			benar salah jawaban soal pilihlah pernyataan kecuali`),
+		prefixes: []string{"meng", "mem", "men", "ber", "ter", "di", "per", "ke"},
+		suffixes: []string{"kan", "nya", "lah", "an"},
+	},
+}
+
+func set(words string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		m[w] = true
+	}
+	return m
+}
+
+// Supported reports whether code is a language the detector knows.
+func Supported(code string) bool {
+	_, ok := profiles[code]
+	return ok
+}
+
+// Name is the English name of code, or code itself when unknown.
+func Name(code string) string {
+	if p, ok := profiles[code]; ok {
+		return p.name
+	}
+	return code
+}
+
+// Candidates parses a Tesseract language list ("eng+ind") into the codes
+// the detector knows.
+func Candidates(ocrLang string) []string {
+	var out []string
+	for _, c := range strings.Split(ocrLang, "+") {
+		if c = strings.TrimSpace(c); Supported(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Detect guesses the language of text among candidates (all known
+// languages when empty). It returns "" when the text is too short or no
+// language stands out.
+func Detect(text string, candidates ...string) string {
+	if len(candidates) == 0 {
+		candidates = []string{English, Indonesian}
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minWords {
+		return ""
+	}
+
+	best, bestScore, second := "", 0.0, 0.0
+	for _, code := range candidates {
+		p, ok := profiles[code]
+		if !ok {
+			continue
+		}
+		score := 0.0
+		for _, w := range words {
+			switch {
+			case p.stopwords[w]:
+				score++
+			case len(w) > 5 && hasAffix(w, p.prefixes, p.suffixes):
+				score += 0.5
+			}
+		}
+		score /= float64(len(words))
+		if score > bestScore {
+			best, bestScore, second = code, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+	// require a clear winner; mixed text stays undetected
+	if bestScore < minScore || bestScore < second*1.2 {
+		return ""
+	}
+	return best
+}
+
+func hasAffix(w string, prefixes, suffixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(w, p) {
+			return true
+		}
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(w, s) {
+			return true
+		}
+	}
+	return false
+}
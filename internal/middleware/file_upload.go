@@ -8,13 +8,21 @@ import (
 	"strings"
 
 	"github.com/emandor/lemme_service/internal/config"
+	"github.com/emandor/lemme_service/internal/img"
 	"github.com/gofiber/fiber/v2"
 )
 
 // file upload validator middleware for checking file type and size
 func FileUploadValidator(cfg *config.Config) fiber.Handler {
-	allowedExt := cfg.AllowedFileExt
-	maxSizeMB := cfg.AllowedMaxFileSize
+	return uploadValidator(cfg.AllowedFileExt, cfg.AllowedMaxFileSize)
+}
+
+// PDFUploadValidator accepts PDF worksheets up to PDFMaxFileSize
+func PDFUploadValidator(cfg *config.Config) fiber.Handler {
+	return uploadValidator([]string{".pdf"}, cfg.PDFMaxFileSize)
+}
+
+func uploadValidator(allowedExt []string, maxSizeMB int) fiber.Handler {
 	extMap := make(map[string]struct{})
 	for _, e := range allowedExt {
 		extMap[strings.ToLower(e)] = struct{}{}
@@ -74,7 +82,7 @@ func validateFile(file *multipart.FileHeader, extMap map[string]struct{}, maxSiz
 	return nil
 }
 
-// verify magic numbers for jpg/jpeg and png
+// verify magic numbers for jpg/jpeg, png, gif, webp, heic/heif and pdf
 func isValidMagic(ext, mimeType string, head []byte) bool {
 	switch ext {
 	case ".jpg", ".jpeg":
@@ -83,6 +91,17 @@ func isValidMagic(ext, mimeType string, head []byte) bool {
 	case ".png":
 		return strings.HasPrefix(mimeType, "image/png") &&
 			bytes.HasPrefix(head, []byte{0x89, 0x50, 0x4E, 0x47})
+	case ".gif":
+		return strings.HasPrefix(mimeType, "image/gif") &&
+			(bytes.HasPrefix(head, []byte("GIF87a")) || bytes.HasPrefix(head, []byte("GIF89a")))
+	case ".webp":
+		return strings.HasPrefix(mimeType, "image/webp") &&
+			len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP"))
+	case ".heic", ".heif":
+		// DetectContentType does not know HEIF, check the ftyp brand only
+		return img.IsHEIF(head)
+	case ".pdf":
+		return strings.HasPrefix(mimeType, "application/pdf") && img.IsPDF(head)
 	default:
 		return false
 	}
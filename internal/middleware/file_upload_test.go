@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/emandor/lemme_service/internal/config"
+)
+
+// uploadForm is a multipart form with one file of size bytes starting with
+// head per name.
+func uploadForm(t *testing.T, field string, head []byte, size int, names ...string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("note", "from the phone")
+	for _, name := range names {
+		fw, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(head)
+		fw.Write(make([]byte, size-len(head)))
+	}
+	mw.Close()
+	return &body, mw.FormDataContentType()
+}
+
+func TestUploadsFitBodyLimit(t *testing.T) {
+	const mb = 1024 * 1024
+	// the defaults, where PDF_MAX_FILE_SIZE equals MAX_BODY_LIMIT
+	cfg := &config.Config{
+		MaxBodyLimit:       8,
+		QuizMaxImages:      3,
+		AllowedMaxFileSize: 2,
+		AllowedFileExt:     []string{".png"},
+		PDFMaxFileSize:     8,
+	}
+	app := fiber.New(fiber.Config{BodyLimit: cfg.BodyLimit()})
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(200) }
+	app.Post("/pdf", PDFUploadValidator(cfg), ok)
+	app.Post("/images", FileUploadValidator(cfg), ok)
+
+	png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	tests := []struct {
+		name   string
+		path   string
+		head   []byte
+		size   int
+		files  int
+		status int
+	}{
+		{"largest pdf", "/pdf", []byte("%PDF-1.7\n"), 8 * mb, 1, 200},
+		{"oversized pdf", "/pdf", []byte("%PDF-1.7\n"), 8*mb + 1, 1, 400},
+		{"largest images", "/images", png, 2 * mb, 3, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := make([]string, tt.files)
+			for i := range names {
+				names[i] = fmt.Sprintf("upload-%d%s", i, map[string]string{"/pdf": ".pdf", "/images": ".png"}[tt.path])
+			}
+			field := map[string]string{"/pdf": "file", "/images": "images"}[tt.path]
+			body, ctype := uploadForm(t, field, tt.head, tt.size, names...)
+			req := httptest.NewRequest("POST", tt.path, body)
+			req.Header.Set("Content-Type", ctype)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	if got := (&config.Config{MaxBodyLimit: 64, PDFMaxFileSize: 8}).BodyLimit(); got != 64*mb {
+		t.Errorf("a larger MAX_BODY_LIMIT is lowered to %d", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
@@ -42,12 +43,44 @@ func NewOpenAIVision(key, model string, rps, burst, maxRetries int) *OpenAIVisio
 }
 
 type Result struct {
-	Text       string
-	Confidence float64
-	Raw        string
+	Text string `json:"text"`
+	// LaTeX is the text with formulas written as LaTeX; set by ReadMath.
+	LaTeX      string  `json:"latex,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Raw        string  `json:"raw,omitempty"`
 }
 
+const (
+	plainInstruction = "Extract plain text (OCR). Return ONLY the raw text (no explanation)."
+	mathInstruction  = `Extract the text (OCR) of this math quiz. Return ONLY a JSON object with two keys:
+"text": the plain text, writing formulas with Unicode symbols (x², √2, ½, ≤),
+"latex": the same text with every formula written as LaTeX between $...$ (e.g. $x^{2}$, $\frac{1}{2}$).
+Keep the line breaks and option labels of the original in both.`
+)
+
 func (o *OpenAIVision) Read(ctx context.Context, imgB []byte, mime string) (Result, error) {
+	return o.read(ctx, imgB, mime, plainInstruction, 512, false)
+}
+
+// ReadMath reads a math quiz as plain text and as text with LaTeX formulas.
+func (o *OpenAIVision) ReadMath(ctx context.Context, imgB []byte, mime string) (Result, error) {
+	// both variants of the text, hence the larger limit
+	res, err := o.read(ctx, imgB, mime, mathInstruction, 1024, true)
+	if err != nil {
+		return res, err
+	}
+	var out struct {
+		Text  string `json:"text"`
+		LaTeX string `json:"latex"`
+	}
+	if err := json.Unmarshal([]byte(res.Text), &out); err != nil {
+		return Result{Raw: res.Raw}, fmt.Errorf("openai vision: math reply: %w", err)
+	}
+	return Result{Text: out.Text, LaTeX: out.LaTeX, Raw: res.Raw}, nil
+}
+
+// read sends the image with instruction; jsonMode asks for a JSON object.
+func (o *OpenAIVision) read(ctx context.Context, imgB []byte, mime, instruction string, maxTokens int, jsonMode bool) (Result, error) {
 	if err := o.Limiter.Wait(ctx); err != nil {
 		return Result{}, err
 	}
@@ -60,13 +93,16 @@ func (o *OpenAIVision) Read(ctx context.Context, imgB []byte, mime string) (Resu
 			map[string]any{
 				"role": "user",
 				"content": []any{
-					map[string]string{"type": "text", "text": "Extract plain text (OCR). Return ONLY the raw text (no explanation)."},
+					map[string]string{"type": "text", "text": instruction},
 					map[string]any{"type": "image_url", "image_url": map[string]any{"url": dataURL, "detail": "low"}},
 				},
 			},
 		},
 		"temperature": 0.0,
-		"max_tokens":  512, // adjust as needed; we need to limit cost
+		"max_tokens":  maxTokens, // adjust as needed; we need to limit cost
+	}
+	if jsonMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
 	}
 
 	b, _ := json.Marshal(payload)
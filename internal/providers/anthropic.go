@@ -7,6 +7,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/emandor/lemme_service/internal/telemetry"
@@ -15,6 +16,13 @@ import (
 type Anthropic struct {
 	Key, Model string
 	DryRun     bool
+	// HTTP sends the requests; nil uses http.DefaultClient.
+	HTTP *Transport
+	// Structured requests the native structured-output mode with the
+	// answer schema.
+	Structured bool
+	// Gen are the sampling and reasoning settings.
+	Gen Generation
 }
 
 func (c *Anthropic) Name() SourceName { return SourceClaude }
@@ -39,21 +47,10 @@ func (c *Anthropic) Ask(ctx context.Context, prompt string) (Answer, error) {
 		}
 		return parsed, nil
 	}
-	body := map[string]any{
-		"model":      c.Model,
-		"max_tokens": 512,
-		"messages": []map[string]any{
-			{"role": "user", "content": prompt},
-		},
-	}
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(b))
-	req.Header.Set("x-api-key", c.Key)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("Content-Type", "application/json")
+	req := c.newRequest(ctx, prompt, false)
 
 	t0 := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doHTTP(c.HTTP, req)
 	if err != nil {
 		return Answer{}, err
 	}
@@ -65,15 +62,95 @@ func (c *Anthropic) Ask(ctx context.Context, prompt string) (Answer, error) {
 	}
 	var out struct {
 		Content []struct {
-			Text string `json:"text"`
+			Type     string          `json:"type"`
+			Text     string          `json:"text"`
+			Thinking string          `json:"thinking"`
+			Input    json.RawMessage `json:"input"`
 		} `json:"content"`
+		StopReason string `json:"stop_reason"`
 	}
 	_ = json.Unmarshal(raw, &out)
+	if out.StopReason == "max_tokens" {
+		return Answer{}, maxTokensError(c.Name())
+	}
 	if len(out.Content) == 0 {
 		return Answer{}, errors.New("anthropic empty content")
 	}
 
-	parsed, _ := TryParseAnswer(out.Content[0].Text)
+	// the answer tool call carries the answer as its input; thinking blocks
+	// come before the text
+	var text, thinking strings.Builder
+	var input json.RawMessage
+	for _, b := range out.Content {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "thinking":
+			thinking.WriteString(b.Thinking)
+		case "tool_use":
+			if input == nil && len(b.Input) > 0 {
+				input = b.Input
+			}
+		}
+	}
+	var parsed Answer
+	if input != nil {
+		parsed = parseOutput(ctx, c.Name(), string(input), true)
+	} else {
+		parsed = parseOutput(ctx, c.Name(), text.String(), false)
+	}
+	parsed.Reasoning = strings.TrimSpace(thinking.String())
 	parsed.LatencyMs = int(time.Since(t0) / time.Millisecond)
 	return parsed, nil
 }
+
+func (c *Anthropic) newRequest(ctx context.Context, prompt string, stream bool) *http.Request {
+	thinking := c.Gen.ThinkingBudget > 0
+	body := map[string]any{
+		"model": c.Model,
+		// the thinking budget is spent out of max_tokens
+		"max_tokens": c.Gen.maxTokens(512) + max(c.Gen.ThinkingBudget, 0),
+		"messages": []map[string]any{
+			{"role": "user", "content": prompt},
+		},
+	}
+	if images := ImagesFrom(ctx); len(images) > 0 {
+		// images go before the text, as Anthropic recommends
+		content := make([]any, 0, len(images)+1)
+		for _, im := range images {
+			content = append(content, map[string]any{
+				"type":   "image",
+				"source": map[string]any{"type": "base64", "media_type": im.MIME, "data": im.base64()},
+			})
+		}
+		content = append(content, map[string]any{"type": "text", "text": prompt})
+		body["messages"] = []map[string]any{{"role": "user", "content": content}}
+	}
+	if thinking {
+		body["thinking"] = map[string]any{"type": "enabled", "budget_tokens": c.Gen.ThinkingBudget}
+	} else if c.Gen.Temperature != nil {
+		body["temperature"] = *c.Gen.Temperature
+	}
+	if c.Structured {
+		body["tools"] = []map[string]any{{
+			"name":         answerToolName,
+			"description":  "Submit the answer to the quiz.",
+			"input_schema": AnswerSchema(PromptKindFrom(ctx)),
+		}}
+		// a forced tool call is not allowed with thinking
+		if thinking {
+			body["tool_choice"] = map[string]any{"type": "auto"}
+		} else {
+			body["tool_choice"] = map[string]any{"type": "tool", "name": answerToolName}
+		}
+	}
+	if stream {
+		body["stream"] = true
+	}
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(b))
+	req.Header.Set("x-api-key", c.Key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
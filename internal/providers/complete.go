@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// Completer is implemented by providers that can answer a prompt with plain
+// text, without the answer schema, reasoning or images. Small jobs such as
+// quiz titles use it with a cheap model.
+type Completer interface {
+	// Complete returns the reply to prompt from model (the provider's
+	// cheapModel when empty), bounded by maxTokens.
+	Complete(ctx context.Context, model, prompt string, maxTokens int) (string, error)
+}
+
+// cheap models of each provider for Complete.
+const (
+	openAICheapModel    = "gpt-4o-mini"
+	anthropicCheapModel = "claude-3-5-haiku-latest"
+	geminiCheapModel    = "gemini-2.0-flash-lite"
+)
+
+// defaultCompleteTokens bounds Complete when maxTokens is not positive.
+const defaultCompleteTokens = 64
+
+func completeTokens(n int) int {
+	if n > 0 {
+		return n
+	}
+	return defaultCompleteTokens
+}
+
+// dryRunCompletion stands in for a reply in DRY_RUN mode.
+const dryRunCompletion = "simulated completion"
+
+func (c *OpenAI) Complete(ctx context.Context, model, prompt string, maxTokens int) (string, error) {
+	if c.DryRun {
+		return dryRunCompletion, nil
+	}
+	raw, err := completeHTTP(c.HTTP, c.Name(), c.newCompletion(ctx, model, prompt, maxTokens))
+	if err != nil {
+		return "", err
+	}
+	if openAICutOff(raw) {
+		return "", maxTokensError(c.Name())
+	}
+	return nonEmpty(c.Name(), extractOpenAIText(raw))
+}
+
+func (c *OpenAI) newCompletion(ctx context.Context, model, prompt string, maxTokens int) *http.Request {
+	if model == "" {
+		model = openAICheapModel
+	}
+	b, _ := json.Marshal(map[string]any{
+		"model":             model,
+		"input":             prompt,
+		"max_output_tokens": completeTokens(maxTokens),
+	})
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/responses", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+c.Key)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (c *Anthropic) Complete(ctx context.Context, model, prompt string, maxTokens int) (string, error) {
+	if c.DryRun {
+		return dryRunCompletion, nil
+	}
+	raw, err := completeHTTP(c.HTTP, c.Name(), c.newCompletion(ctx, model, prompt, maxTokens))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}
+	_ = json.Unmarshal(raw, &out)
+	if out.StopReason == "max_tokens" {
+		return "", maxTokensError(c.Name())
+	}
+	var text strings.Builder
+	for _, b := range out.Content {
+		if b.Type == "text" {
+			text.WriteString(b.Text)
+		}
+	}
+	return nonEmpty(c.Name(), text.String())
+}
+
+func (c *Anthropic) newCompletion(ctx context.Context, model, prompt string, maxTokens int) *http.Request {
+	if model == "" {
+		model = anthropicCheapModel
+	}
+	b, _ := json.Marshal(map[string]any{
+		"model":      model,
+		"max_tokens": completeTokens(maxTokens),
+		"messages":   []map[string]any{{"role": "user", "content": prompt}},
+	})
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(b))
+	req.Header.Set("x-api-key", c.Key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (c *Gemini) Complete(ctx context.Context, model, prompt string, maxTokens int) (string, error) {
+	if c.DryRun {
+		return dryRunCompletion, nil
+	}
+	raw, err := completeHTTP(c.HTTP, c.Name(), c.newCompletion(ctx, model, prompt, maxTokens))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Candidates []struct {
+			Content struct {
+				Parts []geminiPart `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+	}
+	_ = json.Unmarshal(raw, &out)
+	if len(out.Candidates) == 0 {
+		return "", errors.New("gemini empty candidates")
+	}
+	if out.Candidates[0].FinishReason == "MAX_TOKENS" {
+		return "", maxTokensError(c.Name())
+	}
+	text, _ := splitGeminiParts(out.Candidates[0].Content.Parts)
+	return nonEmpty(c.Name(), text)
+}
+
+func (c *Gemini) newCompletion(ctx context.Context, model, prompt string, maxTokens int) *http.Request {
+	if model == "" {
+		model = geminiCheapModel
+	}
+	b, _ := json.Marshal(map[string]any{
+		"contents": []any{map[string]any{
+			"role":  "user",
+			"parts": []any{map[string]string{"text": prompt}},
+		}},
+		"generationConfig": map[string]any{"maxOutputTokens": completeTokens(maxTokens)},
+	})
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", model)
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-goog-api-key", c.Key)
+	return req
+}
+
+// completeHTTP sends a Complete request through t and returns the body of
+// a successful reply.
+func completeHTTP(t *Transport, name SourceName, req *http.Request) ([]byte, error) {
+	resp, err := doHTTP(t, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log := telemetry.L()
+		log.Error().Str("provider", string(name)).Str("status", resp.Status).Msg("complete_http_error")
+		return nil, errors.New(strings.ToLower(string(name)) + " http " + resp.Status)
+	}
+	return raw, nil
+}
+
+// nonEmpty trims text, failing when nothing is left.
+func nonEmpty(name SourceName, text string) (string, error) {
+	if text = strings.TrimSpace(text); text == "" {
+		return "", errors.New(strings.ToLower(string(name)) + ": empty text")
+	}
+	return text, nil
+}
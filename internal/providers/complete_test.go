@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func requestBody(t *testing.T, req *http.Request) map[string]any {
+	t.Helper()
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCompletionRequests(t *testing.T) {
+	temp := 0.2
+	gen := Generation{MaxTokens: 4096, Temperature: &temp, ReasoningEffort: "high", ThinkingBudget: 2048}
+	// the answer context must not leak into a completion
+	ctx := WithImages(WithPromptKind(context.Background(), PromptMulti), []Image{{MIME: "image/png", Data: []byte("png")}})
+	prompt := BuildTitlePrompt("1. What is 2+2?")
+
+	openai := &OpenAI{Model: "gpt-5", Structured: true, Gen: gen}
+	anthropic := &Anthropic{Model: "claude-opus", Structured: true, Gen: gen}
+	gemini := &Gemini{Model: "gemini-2.5-pro", Structured: true, Gen: gen}
+
+	t.Run("openai", func(t *testing.T) {
+		body := requestBody(t, openai.newCompletion(ctx, "", prompt, 32))
+		want := map[string]any{"model": openAICheapModel, "input": prompt, "max_output_tokens": 32.0}
+		if !mapsEqual(body, want) {
+			t.Errorf("body %v, want %v", body, want)
+		}
+	})
+	t.Run("anthropic", func(t *testing.T) {
+		body := requestBody(t, anthropic.newCompletion(ctx, "claude-small", prompt, 0))
+		if body["model"] != "claude-small" || body["max_tokens"] != float64(defaultCompleteTokens) || len(body) != 3 {
+			t.Errorf("body %v", body)
+		}
+		msg := body["messages"].([]any)[0].(map[string]any)
+		if msg["content"] != prompt {
+			t.Errorf("message %v", msg)
+		}
+	})
+	t.Run("gemini", func(t *testing.T) {
+		req := gemini.newCompletion(ctx, "", prompt, 32)
+		if !strings.Contains(req.URL.Path, "/models/"+geminiCheapModel+":generateContent") {
+			t.Errorf("url %s", req.URL)
+		}
+		body := requestBody(t, req)
+		gc := body["generationConfig"].(map[string]any)
+		if len(gc) != 1 || gc["maxOutputTokens"] != 32.0 {
+			t.Errorf("generationConfig %v", gc)
+		}
+		parts := body["contents"].([]any)[0].(map[string]any)["parts"].([]any)
+		if len(parts) != 1 || parts[0].(map[string]any)["text"] != prompt {
+			t.Errorf("parts %v", parts)
+		}
+	})
+}
+
+func mapsEqual(a, b map[string]any) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func TestBuildTitlePrompt(t *testing.T) {
+	p := BuildTitlePrompt(strings.Repeat("é", maxTitleInput+10))
+	if strings.Contains(p, "JSON") || strings.Count(p, "é") != maxTitleInput {
+		t.Errorf("prompt asks for JSON or is not cut at %d runes", maxTitleInput)
+	}
+}
@@ -17,6 +17,13 @@ import (
 type Gemini struct {
 	Key, Model string
 	DryRun     bool
+	// HTTP sends the requests; nil uses http.DefaultClient.
+	HTTP *Transport
+	// Structured requests the native structured-output mode with the
+	// answer schema.
+	Structured bool
+	// Gen are the sampling and reasoning settings.
+	Gen Generation
 }
 
 func (c *Gemini) Name() SourceName { return SourceGemini }
@@ -42,37 +49,16 @@ func (c *Gemini) Ask(ctx context.Context, prompt string) (Answer, error) {
 		return parsed, nil
 	}
 
-	body := map[string]any{
-		"contents": []any{
-			map[string]any{
-				"role": "user",
-				"parts": []any{
-					map[string]string{"text": prompt},
-				},
-			},
-		},
-		"generationConfig": map[string]any{
-			"temperature":      0.0,
-			"maxOutputTokens":  256,
-			"responseMimeType": "application/json",
-		},
-	}
-
-	b, errBody := json.Marshal(body)
-	if errBody != nil {
-		return Answer{}, errBody
+	req, n, err := c.newRequest(ctx, prompt, false)
+	if err != nil {
+		return Answer{}, err
 	}
 
-	log := telemetry.L().With().Str("provider", string(c.Name())).Int("body_len", len(b)).Logger()
+	log := telemetry.L().With().Str("provider", string(c.Name())).Int("body_len", n).Logger()
 	log.Debug().Msg("gemini_request")
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", c.Model)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-goog-api-key", c.Key)
-
 	t0 := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doHTTP(c.HTTP, req)
 	if err != nil {
 		log.Error().Err(err).Msg("gemini_request_failed")
 		return Answer{}, err
@@ -90,9 +76,7 @@ func (c *Gemini) Ask(ctx context.Context, prompt string) (Answer, error) {
 	var out struct {
 		Candidates []struct {
 			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
+				Parts []geminiPart `json:"parts"`
 			} `json:"content"`
 			FinishReason string `json:"finishReason"`
 		} `json:"candidates"`
@@ -109,9 +93,13 @@ func (c *Gemini) Ask(ctx context.Context, prompt string) (Answer, error) {
 		return Answer{}, errors.New("gemini blocked: " + out.PromptFeedback.BlockReason)
 	}
 
-	var text string
-	if len(out.Candidates) > 0 && len(out.Candidates[0].Content.Parts) > 0 {
-		text = out.Candidates[0].Content.Parts[0].Text
+	var text, thoughts string
+	if len(out.Candidates) > 0 {
+		if out.Candidates[0].FinishReason == "MAX_TOKENS" {
+			log.Warn().Msg("gemini_max_tokens")
+			return Answer{}, maxTokensError(c.Name())
+		}
+		text, thoughts = splitGeminiParts(out.Candidates[0].Content.Parts)
 	}
 
 	text = strings.TrimSpace(text)
@@ -119,7 +107,73 @@ func (c *Gemini) Ask(ctx context.Context, prompt string) (Answer, error) {
 		return Answer{}, errors.New("gemini empty candidates")
 	}
 
-	parsed, _ := TryParseAnswer(text)
+	parsed := parseOutput(ctx, c.Name(), text, c.Structured)
+	parsed.Reasoning = strings.TrimSpace(thoughts)
 	parsed.LatencyMs = int(time.Since(t0) / time.Millisecond)
 	return parsed, nil
 }
+
+// newRequest builds a generateContent call (streamGenerateContent over SSE
+// with stream) and returns it with its body size.
+func (c *Gemini) newRequest(ctx context.Context, prompt string, stream bool) (*http.Request, int, error) {
+	parts := []any{}
+	for _, im := range ImagesFrom(ctx) {
+		parts = append(parts, map[string]any{"inlineData": map[string]any{"mimeType": im.MIME, "data": im.base64()}})
+	}
+	parts = append(parts, map[string]string{"text": prompt})
+	body := map[string]any{
+		"contents": []any{
+			map[string]any{
+				"role":  "user",
+				"parts": parts,
+			},
+		},
+	}
+	gen := map[string]any{
+		"maxOutputTokens":  c.Gen.maxTokens(256),
+		"responseMimeType": "application/json",
+	}
+	if c.Gen.Temperature != nil {
+		gen["temperature"] = *c.Gen.Temperature
+	}
+	if c.Gen.ThinkingBudget != 0 {
+		gen["thinkingConfig"] = map[string]any{"thinkingBudget": c.Gen.ThinkingBudget, "includeThoughts": true}
+	}
+	if c.Structured {
+		gen["responseSchema"] = geminiSchema(AnswerSchema(PromptKindFrom(ctx)))
+	}
+	body["generationConfig"] = gen
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", c.Model)
+	if stream {
+		url = fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse", c.Model)
+	}
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-goog-api-key", c.Key)
+	return req, len(b), nil
+}
+
+// geminiPart is a part of a reply; Thought marks the model's thoughts.
+type geminiPart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought"`
+}
+
+// splitGeminiParts joins the answer text and the thoughts of parts.
+func splitGeminiParts(parts []geminiPart) (text, thoughts string) {
+	var t, th strings.Builder
+	for _, p := range parts {
+		if p.Thought {
+			th.WriteString(p.Text)
+		} else {
+			t.WriteString(p.Text)
+		}
+	}
+	return t.String(), th.String()
+}
@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMaxTokens is returned when a reply stopped at the token limit; its
+// text is cut short and is not parsed.
+var ErrMaxTokens = errors.New("reply cut off at max tokens")
+
+// Generation are the sampling and reasoning settings of a provider.
+type Generation struct {
+	// MaxTokens bounds the reply. OpenAI and Gemini count reasoning
+	// tokens against it; Anthropic gets ThinkingBudget on top.
+	MaxTokens int
+	// Temperature is left out when nil. It is never sent with OpenAI
+	// reasoning or Anthropic thinking, which reject it.
+	Temperature *float64
+	// ReasoningEffort is OpenAI's reasoning effort ("minimal", "low",
+	// "medium", "high"); empty sends none.
+	ReasoningEffort string
+	// ThinkingBudget is the Anthropic extended-thinking budget (at least
+	// 1024) or the Gemini thinkingBudget (-1 lets the model decide); 0
+	// keeps the provider default.
+	ThinkingBudget int
+}
+
+// maxTokens is g.MaxTokens, or d when unset.
+func (g Generation) maxTokens(d int) int {
+	if g.MaxTokens > 0 {
+		return g.MaxTokens
+	}
+	return d
+}
+
+// maxTokensError reports a reply cut off at the token limit.
+func maxTokensError(name SourceName) error {
+	return fmt.Errorf("%s: %w", strings.ToLower(string(name)), ErrMaxTokens)
+}
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emandor/lemme_service/internal/lang"
+)
+
+// parseKeywords are the words the plain-text parsing strategies look for
+// in one language.
+type parseKeywords struct {
+	Answer, Reason, True, False []string
+	// Decimal is the decimal separator of numbers (see normalizeNumbers).
+	Decimal byte
+}
+
+// keywordsByLang is extended per language; English is always included
+// since models often answer in English whatever the question language.
+var keywordsByLang = map[string]parseKeywords{
+	lang.English: {
+		Answer:  []string{"answer", "final"},
+		Reason:  []string{"reason"},
+		True:    []string{"true"},
+		False:   []string{"false"},
+		Decimal: '.',
+	},
+	lang.Indonesian: {
+		Answer:  []string{"jawaban"},
+		Reason:  []string{"penjelasan", "alasan"},
+		True:    []string{"ya", "benar", "betul"},
+		False:   []string{"tidak", "salah", "keliru"},
+		Decimal: ',',
+	},
+}
+
+type parseRx struct {
+	ansColon, reaColon, boolean, final *regexp.Regexp
+	truthy, falsy                      map[string]bool
+	// decimal is the decimal separator of the language, 0 when the
+	// keywords of several languages are combined.
+	decimal byte
+}
+
+// parsers holds the compiled keywords per language; "" combines them all.
+var parsers = map[string]*parseRx{}
+
+func init() {
+	codes := make([]string, 0, len(keywordsByLang))
+	for code := range keywordsByLang {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var all parseKeywords
+	for _, code := range codes {
+		kw := keywordsByLang[code]
+		all = mergeKeywords(all, kw)
+		if code != lang.English {
+			kw = mergeKeywords(keywordsByLang[lang.English], kw)
+		}
+		parsers[code] = compileKeywords(kw)
+		parsers[code].decimal = keywordsByLang[code].Decimal
+	}
+	parsers[""] = compileKeywords(all)
+}
+
+func mergeKeywords(a, b parseKeywords) parseKeywords {
+	return parseKeywords{
+		Answer: append(append([]string{}, a.Answer...), b.Answer...),
+		Reason: append(append([]string{}, a.Reason...), b.Reason...),
+		True:   append(append([]string{}, a.True...), b.True...),
+		False:  append(append([]string{}, a.False...), b.False...),
+	}
+}
+
+func compileKeywords(kw parseKeywords) *parseRx {
+	alt := func(ws []string) string { return strings.Join(ws, "|") }
+	// "final" reads as a marker ("Final: B"), not as a colon heading
+	var answerHeads []string
+	for _, w := range kw.Answer {
+		if w != "final" {
+			answerHeads = append(answerHeads, w)
+		}
+	}
+	bools := alt(kw.True) + "|" + alt(kw.False)
+	p := &parseRx{
+		ansColon: regexp.MustCompile(`(?im)^(?:` + alt(answerHeads) + `)\s*[:：]\s*(.+)$`),
+		reaColon: regexp.MustCompile(`(?im)^(?:` + alt(kw.Reason) + `)\s*[:：]\s*(.+)$`),
+		boolean:  regexp.MustCompile(`(?i)\b(` + bools + `)\b`),
+		final:    regexp.MustCompile(`(?i)\b(` + alt(kw.Answer) + `)\b[:：]?\s*([A-Z]|` + numberPattern + `|` + bools + `)\b`),
+		truthy:   map[string]bool{},
+		falsy:    map[string]bool{},
+	}
+	for _, w := range kw.True {
+		p.truthy[w] = true
+	}
+	for _, w := range kw.False {
+		p.falsy[w] = true
+	}
+	return p
+}
+
+// numberPattern matches the numbers a final answer may be: integers,
+// decimals with either separator and fractions.
+const numberPattern = `-?\d+(?:[.,]\d+)*(?:\s*/\s*\d+)?`
+
+func parserFor(code string) *parseRx {
+	if p, ok := parsers[code]; ok {
+		return p
+	}
+	return parsers[""]
+}
+
+type langKey struct{}
+
+// WithLanguage tells the providers the quiz language, so that plain-text
+// answers are parsed with that language's keywords.
+func WithLanguage(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, langKey{}, code)
+}
+
+// LanguageFrom returns the language set by WithLanguage, or "".
+func LanguageFrom(ctx context.Context) string {
+	code, _ := ctx.Value(langKey{}).(string)
+	return code
+}
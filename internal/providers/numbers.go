@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	rxFraction   = regexp.MustCompile(`^([-+]?)\s*(\d+)\s*/\s*(\d+)$`)
+	rxTeXFrac    = regexp.MustCompile(`^([-+]?)\s*\\[dt]?frac\s*\{\s*(\d+)\s*\}\s*\{\s*(\d+)\s*\}$`)
+	rxDecimal    = regexp.MustCompile(`^[-+]?\d+(?:[.,]\d+)*$`)
+	vulgarFracts = map[string]string{
+		"½": "1/2", "⅓": "1/3", "⅔": "2/3", "¼": "1/4", "¾": "3/4",
+		"⅕": "1/5", "⅖": "2/5", "⅗": "3/5", "⅘": "4/5", "⅛": "1/8",
+	}
+)
+
+// normalizeNumbers writes the numeric answers of a (or of its parts) one
+// way (see normalizeNumber), reading decimals with the separator decimal of
+// the quiz language. Without a language (decimal 0) nothing changes, nor do
+// answers that are not a single number such as "1,2,3".
+func normalizeNumbers(a *Answer, decimal byte) {
+	if decimal == 0 {
+		return
+	}
+	if len(a.Parts) == 0 {
+		if n, ok := normalizeNumber(a.Answer, decimal); ok {
+			a.Answer = n
+		}
+		return
+	}
+	for i := range a.Parts {
+		if n, ok := normalizeNumber(a.Parts[i].Answer, decimal); ok {
+			a.Parts[i].Answer = n
+		}
+	}
+	a.Answer = partsSummary(a.Parts)
+}
+
+// NormalizeNumber writes s the way parsed answers of language code write
+// numbers, or returns it as it is when it is no number of that language.
+func NormalizeNumber(s, code string) string {
+	decimal := parserFor(code).decimal
+	if decimal == 0 {
+		return s
+	}
+	if n, ok := normalizeNumber(s, decimal); ok {
+		return n
+	}
+	return s
+}
+
+// normalizeNumber writes a numeric answer one way: reduced fractions
+// ("6/8", "\frac{6}{8}", "¾" → "3/4") and decimals with a "." point, no
+// thousands separators and no trailing zeros ("2,50" → "2.5", "1.000,5" →
+// "1000.5" for decimal ','). decimal is the decimal separator of the quiz
+// language; the other separator may only group thousands. ok is false when
+// s is not a number written that way.
+func normalizeNumber(s string, decimal byte) (string, bool) {
+	s = strings.Trim(strings.TrimSpace(s), "$")
+	if f, ok := vulgarFracts[s]; ok {
+		s = f
+	}
+	m := rxFraction.FindStringSubmatch(s)
+	if m == nil {
+		m = rxTeXFrac.FindStringSubmatch(s)
+	}
+	if m != nil {
+		return reduceFraction(m[1], m[2], m[3])
+	}
+	if !rxDecimal.MatchString(s) {
+		return "", false
+	}
+
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		if s[0] == '-' {
+			sign = "-"
+		}
+		s = s[1:]
+	}
+	intPart, frac, ok := splitDecimal(s, decimal)
+	if !ok {
+		return "", false
+	}
+	if frac = strings.TrimRight(frac, "0"); frac != "" {
+		return sign + intPart + "." + frac, true
+	}
+	return sign + intPart, true
+}
+
+// splitDecimal splits digits at the decimal separator and drops the
+// thousands separators of the integer part. ok is false for a repeated
+// decimal separator or misplaced thousands separators, as in lists like
+// "1,2,3" or "1.2.3".
+func splitDecimal(digits string, decimal byte) (intPart, frac string, ok bool) {
+	thousands := ","
+	if decimal == ',' {
+		thousands = "."
+	}
+	intPart = digits
+	switch strings.Count(digits, string(decimal)) {
+	case 0:
+	case 1:
+		i := strings.IndexByte(digits, decimal)
+		intPart, frac = digits[:i], digits[i+1:]
+	default:
+		return "", "", false
+	}
+	if strings.Contains(frac, thousands) {
+		return "", "", false
+	}
+	groups := strings.Split(intPart, thousands)
+	if len(groups) == 1 {
+		return intPart, frac, true
+	}
+	if len(groups[0]) > 3 {
+		return "", "", false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return "", "", false
+		}
+	}
+	return strings.Join(groups, ""), frac, true
+}
+
+func reduceFraction(sign, num, den string) (string, bool) {
+	n, err1 := strconv.Atoi(num)
+	d, err2 := strconv.Atoi(den)
+	if err1 != nil || err2 != nil || d == 0 {
+		return "", false
+	}
+	if sign == "+" || n == 0 {
+		sign = ""
+	}
+	g := gcd(n, d)
+	n, d = n/g, d/g
+	if d == 1 {
+		return sign + strconv.Itoa(n), true
+	}
+	return sign + strconv.Itoa(n) + "/" + strconv.Itoa(d), true
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/emandor/lemme_service/internal/lang"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		decimal byte
+		want    string
+		ok      bool
+	}{
+		{"42", '.', "42", true},
+		{"-7", ',', "-7", true},
+		{"+3", '.', "3", true},
+		{"2.50", '.', "2.5", true},
+		{"2,50", ',', "2.5", true},
+		{"1,3", ',', "1.3", true},
+		{"0,250", ',', "0.25", true},
+		{"1,000", '.', "1000", true},
+		{"1.000", ',', "1000", true},
+		{"1.000", '.', "1", true},
+		{"1,000.5", '.', "1000.5", true},
+		{"1.000,5", ',', "1000.5", true},
+		{"1,000,000", '.', "1000000", true},
+		{"$12.0$", '.', "12", true},
+		{"6/8", '.', "3/4", true},
+		{"-4/2", ',', "-2", true},
+		{`\frac{6}{8}`, '.', "3/4", true},
+		{`\dfrac{10}{4}`, ',', "5/2", true},
+		{"¾", '.', "3/4", true},
+
+		// lists and other separators are not numbers of the language
+		{"1,3", '.', "", false},
+		{"1,2,3", '.', "", false},
+		{"1,2,3", ',', "", false},
+		{"1.2.3", '.', "", false},
+		{"1.2.3", ',', "", false},
+		{"1,000.5", ',', "", false},
+		{"12,34,567", '.', "", false},
+		{"1/0", '.', "", false},
+		{"B", '.', "", false},
+		{"x = 2", '.', "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeNumber(tt.in, tt.decimal)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeNumber(%q, %q) = %q, %v; want %q, %v", tt.in, tt.decimal, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		in   Answer
+		want string
+	}{
+		{"english decimal", lang.English, Answer{Answer: "2.50"}, "2.5"},
+		{"indonesian decimal", lang.Indonesian, Answer{Answer: "1,3"}, "1.3"},
+		{"english list", lang.English, Answer{Answer: "1,3"}, "1,3"},
+		{"multi-select", lang.Indonesian, Answer{Answer: "1,2,3"}, "1,2,3"},
+		{"dotted list", lang.English, Answer{Answer: "1.2.3"}, "1.2.3"},
+		{"unknown language", "", Answer{Answer: "2,50"}, "2,50"},
+		{"letter", lang.English, Answer{Answer: "C"}, "C"},
+		{"parts", lang.Indonesian, Answer{
+			Answer: "1: 2,50; 2: A",
+			Parts:  []Answer{{Number: 1, Answer: "2,50"}, {Number: 2, Answer: "A"}},
+		}, "1: 2.5; 2: A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.in
+			normalizeNumbers(&a, keywordsByLang[tt.code].Decimal)
+			if a.Answer != tt.want {
+				t.Errorf("answer = %q, want %q", a.Answer, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNormalizesNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		in   string
+		want string
+	}{
+		{"indonesian json", lang.Indonesian, `{"answer":"2,50","reason":"r"}`, "2.5"},
+		{"english json", lang.English, `{"answer":"2.50"}`, "2.5"},
+		{"indonesian colon", lang.Indonesian, "Jawaban: 1.000,5\nAlasan: r", "1000.5"},
+		{"indonesian keyword", lang.Indonesian, "jadi jawaban 2,5", "2.5"},
+		{"fraction", lang.English, "Final: 6/8", "3/4"},
+		{"parts", lang.Indonesian, `[{"answer":"2,50"},{"answer":"a"}]`, "1: 2.5; 2: a"},
+		{"english list", lang.English, "Answer: 1,3", "1,3"},
+		{"multi-select", lang.Indonesian, `{"answer":"1,2,3"}`, "1,2,3"},
+		{"dotted list", lang.English, `{"answer":"1.2.3"}`, "1.2.3"},
+		{"unknown language", "", `{"answer":"2,50"}`, "2,50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ans, err := TryParseAnswerLang(tt.in, tt.code)
+			if err != nil || ans.Answer != tt.want {
+				t.Errorf("answer = %q, %v; want %q", ans.Answer, err, tt.want)
+			}
+			if ans.Raw != tt.in {
+				t.Errorf("raw = %q", ans.Raw)
+			}
+		})
+	}
+
+	ans, err := parseStructured(`{"answer":"0,250","reason":"r"}`, PromptSingle, lang.Indonesian)
+	if err != nil || ans.Answer != "0.25" {
+		t.Errorf("structured answer = %q, %v", ans.Answer, err)
+	}
+}
+
+func TestNormalizeNumberOfLanguage(t *testing.T) {
+	for _, tt := range []struct{ in, code, want string }{
+		{"2,5", lang.Indonesian, "2.5"},
+		{"2,5", lang.English, "2,5"},
+		{"1,000", "", "1,000"},
+		{"Paris", lang.English, "Paris"},
+	} {
+		if got := NormalizeNumber(tt.in, tt.code); got != tt.want {
+			t.Errorf("NormalizeNumber(%q, %q) = %q, want %q", tt.in, tt.code, got, tt.want)
+		}
+	}
+}
@@ -16,6 +16,13 @@ import (
 type OpenAI struct {
 	Key, Model string
 	DryRun     bool
+	// HTTP sends the requests; nil uses http.DefaultClient.
+	HTTP *Transport
+	// Structured requests the native structured-output mode with the
+	// answer schema.
+	Structured bool
+	// Gen are the sampling and reasoning settings.
+	Gen Generation
 }
 
 func (c *OpenAI) Name() SourceName { return SourceOpenAI }
@@ -41,24 +48,11 @@ func (c *OpenAI) Ask(ctx context.Context, prompt string) (Answer, error) {
 		}
 		return parsed, nil
 	}
-	body := map[string]any{
-		"model":             c.Model,
-		"input":             prompt,
-		"temperature":       0.0,
-		"max_output_tokens": 256,
-	}
-
-	b, _ := json.Marshal(body)
-	log := telemetry.L().With().Str("provider", string(c.Name())).Int("body_len", len(b)).Logger()
-	// redacted log API key
-	b = bytes.ReplaceAll(b, []byte(c.Key), []byte("REDACTED"))
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/responses", bytes.NewReader(b))
-	req.Header.Set("Authorization", "Bearer "+c.Key)
-	req.Header.Set("Content-Type", "application/json")
+	req, n := c.newRequest(ctx, prompt, false)
+	log := telemetry.L().With().Str("provider", string(c.Name())).Int("body_len", n).Logger()
 
 	t0 := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doHTTP(c.HTTP, req)
 	if err != nil {
 		log.Error().Err(err).Msg("openai_request_failed")
 		return Answer{}, err
@@ -76,6 +70,11 @@ func (c *OpenAI) Ask(ctx context.Context, prompt string) (Answer, error) {
 		return Answer{}, errors.New("openai http " + resp.Status)
 	}
 
+	if openAICutOff(raw) {
+		log.Warn().Msg("openai_max_tokens")
+		return Answer{}, maxTokensError(c.Name())
+	}
+
 	// parse: responses API: fallback to chat completions
 	text := extractOpenAIText(raw)
 	if strings.TrimSpace(text) == "" {
@@ -83,7 +82,8 @@ func (c *OpenAI) Ask(ctx context.Context, prompt string) (Answer, error) {
 		return Answer{}, errors.New("openai: empty text")
 	}
 
-	parsed, _ := TryParseAnswer(text)
+	parsed := parseOutput(ctx, c.Name(), text, c.Structured)
+	parsed.Reasoning = extractOpenAIReasoning(raw)
 	parsed.LatencyMs = int(time.Since(t0) / time.Millisecond)
 
 	// usage (if any) → put into TokenUsage
@@ -97,6 +97,46 @@ func (c *OpenAI) Ask(ctx context.Context, prompt string) (Answer, error) {
 	return parsed, nil
 }
 
+// newRequest builds a Responses API call and returns it with its body size.
+func (c *OpenAI) newRequest(ctx context.Context, prompt string, stream bool) (*http.Request, int) {
+	body := map[string]any{
+		"model":             c.Model,
+		"input":             prompt,
+		"max_output_tokens": c.Gen.maxTokens(256),
+	}
+	if images := ImagesFrom(ctx); len(images) > 0 {
+		content := []any{map[string]any{"type": "input_text", "text": prompt}}
+		for _, im := range images {
+			content = append(content, map[string]any{"type": "input_image", "image_url": im.dataURL()})
+		}
+		body["input"] = []any{map[string]any{"role": "user", "content": content}}
+	}
+	if c.Gen.ReasoningEffort != "" {
+		body["reasoning"] = map[string]any{"effort": c.Gen.ReasoningEffort, "summary": "auto"}
+	} else if c.Gen.Temperature != nil {
+		body["temperature"] = *c.Gen.Temperature
+	}
+	if c.Structured {
+		body["text"] = map[string]any{
+			"format": map[string]any{
+				"type":   "json_schema",
+				"name":   "quiz_answer",
+				"schema": AnswerSchema(PromptKindFrom(ctx)),
+				"strict": true,
+			},
+		}
+	}
+	if stream {
+		body["stream"] = true
+	}
+
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/responses", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+c.Key)
+	req.Header.Set("Content-Type", "application/json")
+	return req, len(b)
+}
+
 // get text from Responses API or fallback Chat Completions.
 func extractOpenAIText(raw []byte) string {
 	// resonses API: https://platform.openai.com/docs/api-reference/responses
@@ -107,19 +147,26 @@ func extractOpenAIText(raw []byte) string {
 		return r1.OutputText
 	}
 
-	// response API: output[].content[].text
+	// response API: output[].content[].text of the message item; reasoning
+	// models put a reasoning item first
 	var r2 struct {
 		Output []struct {
+			Type    string `json:"type"`
 			Content []struct {
 				Type string `json:"type"`
 				Text string `json:"text"`
 			} `json:"content"`
 		} `json:"output"`
 	}
-	if json.Unmarshal(raw, &r2) == nil && len(r2.Output) > 0 {
-		for _, c := range r2.Output[0].Content {
-			if strings.TrimSpace(c.Text) != "" {
-				return c.Text
+	if json.Unmarshal(raw, &r2) == nil {
+		for _, o := range r2.Output {
+			if o.Type == "reasoning" {
+				continue
+			}
+			for _, c := range o.Content {
+				if strings.TrimSpace(c.Text) != "" {
+					return c.Text
+				}
 			}
 		}
 	}
@@ -138,3 +185,52 @@ func extractOpenAIText(raw []byte) string {
 
 	return ""
 }
+
+// extractOpenAIReasoning joins the reasoning summaries of a Responses API
+// reply.
+func extractOpenAIReasoning(raw []byte) string {
+	var r struct {
+		Output []struct {
+			Type    string `json:"type"`
+			Summary []struct {
+				Text string `json:"text"`
+			} `json:"summary"`
+		} `json:"output"`
+	}
+	if json.Unmarshal(raw, &r) != nil {
+		return ""
+	}
+	var parts []string
+	for _, o := range r.Output {
+		if o.Type != "reasoning" {
+			continue
+		}
+		for _, s := range o.Summary {
+			if t := strings.TrimSpace(s.Text); t != "" {
+				parts = append(parts, t)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// openAICutOff reports a response that stopped at max_output_tokens, either
+// as a Responses API object or a chat completion.
+func openAICutOff(raw []byte) bool {
+	var r struct {
+		Status            string `json:"status"`
+		IncompleteDetails *struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(raw, &r) != nil {
+		return false
+	}
+	if r.Status == "incomplete" && r.IncompleteDetails != nil && r.IncompleteDetails.Reason == "max_output_tokens" {
+		return true
+	}
+	return len(r.Choices) > 0 && r.Choices[0].FinishReason == "length"
+}
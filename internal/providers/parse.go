@@ -2,47 +2,120 @@ package providers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// ErrUnparsed is returned with the raw fallback answer when no strategy
+// recognised an answer in the reply.
+var ErrUnparsed = errors.New("no answer recognised in reply")
+
 // TryParseAnswer attempts various strategies to normalize LLM answers.
-// Priorities: JSON -> code fence JSON -> first JSON object -> "Answer:"/"Jawaban:" pattern -> letter/number/boolean -> fallback raw.
+// Priorities: JSON -> JSON array (multi-question) -> code fence JSON -> first JSON array/object -> "Answer:"/"Jawaban:" pattern -> letter/number/boolean -> fallback raw.
+// Keywords of every known language are accepted; see TryParseAnswerLang.
+// The answer records the matched strategy, a confidence and warnings; the
+// raw fallback also returns ErrUnparsed.
 func TryParseAnswer(content string) (Answer, error) {
+	return TryParseAnswerLang(content, "")
+}
+
+// TryParseAnswerLang is TryParseAnswer with the plain-text keywords of
+// language code (plus English); "" accepts all of them.
+func TryParseAnswerLang(content, code string) (Answer, error) {
 	ans := Answer{Raw: strings.TrimSpace(content)}
+	kw := parserFor(code)
 
 	if tryJSON(content, &ans) {
-		normalize(&ans)
-		return ans, nil
+		return parsed(ans, ParseJSON, kw), nil
 	}
 
-	if s := extractCodeFenceJSON(content); s != "" && tryJSON(s, &ans) {
-		normalize(&ans)
-		return ans, nil
+	// multi-question answers come as {"answers": [{number, answer, reason}]},
+	// or as the bare array
+	if tryJSONArray(content, &ans) {
+		return parsed(ans, ParseJSON, kw), nil
+	}
+	if s := extractCodeFenceJSONArray(content); s != "" && tryJSONArray(s, &ans) {
+		return parsed(ans, ParseJSONFenced, kw), nil
 	}
 
-	if s := extractFirstJSONObject(content); s != "" && tryJSON(s, &ans) {
-		normalize(&ans)
-		return ans, nil
+	if s := extractCodeFenceJSON(content); s != "" && (tryJSON(s, &ans) || tryJSONArray(s, &ans)) {
+		return parsed(ans, ParseJSONFenced, kw), nil
 	}
 
-	if a, r := parseColonStyle(content); a != "" {
+	// an array opening before any object is a (prose-wrapped) multi answer
+	if arr, obj := strings.Index(content, "["), strings.Index(content, "{"); arr >= 0 && arr < obj {
+		if s := extractFirstJSON(content[arr:], '[', ']'); s != "" && tryJSONArray(s, &ans) {
+			return parsed(ans, ParseJSONExtracted, kw), nil
+		}
+	}
+
+	if s := extractFirstJSONObject(content); s != "" && (tryJSON(s, &ans) || tryJSONArray(s, &ans)) {
+		return parsed(ans, ParseJSONExtracted, kw), nil
+	}
+
+	if a, r := parseColonStyle(content, kw); a != "" {
 		ans.Answer, ans.Reason = a, r
-		normalize(&ans)
-		return ans, nil
+		return parsed(ans, ParseColon, kw), nil
 	}
 
-	if a := parseSimpleFinal(content); a != "" {
+	if a, conf, warn := parseSimpleFinal(content, kw); a != "" {
 		ans.Answer = a
-		normalize(&ans)
+		ans = parsed(ans, ParseKeyword, kw)
+		ans.ParseConfidence = conf
+		ans.ParseWarnings = append(ans.ParseWarnings, warn...)
 		return ans, nil
 	}
 
-	ans.Answer = truncateSingleLine(ans.Raw, 500)
-	normalize(&ans)
-	return ans, nil
+	ans.Answer = truncateSingleLine(ans.Raw, maxRawAnswer)
+	ans = parsed(ans, ParseRaw, kw)
+	ans.ParseWarnings = append(ans.ParseWarnings, "no answer pattern found; the raw reply is used")
+	if len(ans.Raw) > maxRawAnswer {
+		ans.ParseWarnings = append(ans.ParseWarnings, "reply truncated to "+strconv.Itoa(maxRawAnswer)+" bytes")
+	}
+	return ans, ErrUnparsed
+}
+
+// maxRawAnswer bounds the raw reply used as the answer.
+const maxRawAnswer = 500
+
+// parseConfidence is how far each strategy is trusted to have found the
+// answer the model meant. Keyword answers refine theirs in parseSimpleFinal.
+var parseConfidence = map[string]float64{
+	ParseStructured:    1,
+	ParseJSON:          0.95,
+	ParseJSONFenced:    0.9,
+	ParseJSONExtracted: 0.75,
+	ParseColon:         0.6,
+	ParseKeyword:       0.4,
+	ParseRaw:           0,
+}
+
+// parsed normalizes ans and records how it was read. Multi-question
+// answers are normalized per part by tryJSONArray. Numeric answers are
+// written one way in the decimals of kw's language; see normalizeNumbers.
+func parsed(ans Answer, mode string, kw *parseRx) Answer {
+	if len(ans.Parts) == 0 {
+		normalize(&ans)
+	}
+	normalizeNumbers(&ans, kw.decimal)
+	ans.ParseMode = mode
+	ans.ParseConfidence = parseConfidence[mode]
+	if mode == ParseJSONExtracted {
+		ans.ParseWarnings = append(ans.ParseWarnings, "answer JSON was surrounded by other text")
+	}
+	seen := map[int]bool{}
+	for _, p := range ans.Parts {
+		if seen[p.Number] {
+			ans.ParseWarnings = append(ans.ParseWarnings, "question "+strconv.Itoa(p.Number)+" answered more than once")
+		}
+		seen[p.Number] = true
+	}
+	return ans
 }
 
 func tryJSON(s string, out *Answer) bool {
@@ -120,18 +193,89 @@ func extractCodeFenceJSON(s string) string {
 	return ""
 }
 
+var rxFenceArray = regexp.MustCompile("(?is)```(?:json)?\\s*(\\[[\\s\\S]*?\\])\\s*```")
+
+func extractCodeFenceJSONArray(s string) string {
+	m := rxFenceArray.FindStringSubmatch(s)
+	if len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
+
+// tryJSONArray parses [{number, answer, reason}, ...], or the same list
+// wrapped as {"answers": [...]} by the structured-output schema, into
+// out.Parts and sums the parts up in out.Answer ("1: A; 2: C").
+func tryJSONArray(s string, out *Answer) bool {
+	var items []map[string]any
+	if json.Unmarshal([]byte(strings.TrimSpace(s)), &items) != nil {
+		var wrapped struct {
+			Answers []map[string]any `json:"answers"`
+		}
+		if json.Unmarshal([]byte(strings.TrimSpace(s)), &wrapped) != nil {
+			return false
+		}
+		items = wrapped.Answers
+	}
+	if len(items) == 0 {
+		return false
+	}
+	parts := make([]Answer, 0, len(items))
+	for i, m := range items {
+		var p Answer
+		if v, ok := m["answer"]; ok {
+			p.Answer = str(v)
+		}
+		if v, ok := m["reason"]; ok {
+			p.Reason = str(v)
+		}
+		p.Number = i + 1
+		if v, ok := m["number"]; ok {
+			if n := int(toFloat(v)); n > 0 {
+				p.Number = n
+			}
+		}
+		if p.Answer == "" {
+			continue
+		}
+		normalize(&p)
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return false
+	}
+
+	out.Parts = parts
+	out.Answer = partsSummary(parts)
+	normalize(out)
+	return true
+}
+
+// partsSummary sums per-question answers up as "1: A; 2: C".
+func partsSummary(parts []Answer) string {
+	summary := make([]string, len(parts))
+	for i, p := range parts {
+		summary[i] = strconv.Itoa(p.Number) + ": " + p.Answer
+	}
+	return strings.Join(summary, "; ")
+}
+
 // find the first JSON object by simple brace balancing
 func extractFirstJSONObject(s string) string {
-	start := strings.Index(s, "{")
+	return extractFirstJSON(s, '{', '}')
+}
+
+func extractFirstJSON(s string, open, close byte) string {
+	start := strings.IndexByte(s, open)
 	if start < 0 {
 		return ""
 	}
 	level := 0
 	for i := start; i < len(s); i++ {
 		switch s[i] {
-		case '{':
+		case open:
 			level++
-		case '}':
+		case close:
 			level--
 			if level == 0 {
 				return s[start : i+1]
@@ -141,49 +285,101 @@ func extractFirstJSONObject(s string) string {
 	return ""
 }
 
-var rxAnsColon = regexp.MustCompile(`(?im)^(?:answer|jawaban)\s*[:：]\s*(.+)$`)
-var rxReaColon = regexp.MustCompile(`(?im)^(?:reason|penjelasan)\s*[:：]\s*(.+)$`)
-
-func parseColonStyle(s string) (answer, reason string) {
-	if m := rxAnsColon.FindStringSubmatch(s); len(m) > 1 {
+func parseColonStyle(s string, kw *parseRx) (answer, reason string) {
+	if m := kw.ansColon.FindStringSubmatch(s); len(m) > 1 {
 		answer = strings.TrimSpace(m[1])
 	}
-	if m := rxReaColon.FindStringSubmatch(s); len(m) > 1 {
+	if m := kw.reaColon.FindStringSubmatch(s); len(m) > 1 {
 		reason = strings.TrimSpace(m[1])
 	}
 	return
 }
 
 var (
-	rxLetter = regexp.MustCompile(`(?i)\b([A-Z])\b`)       // A..Z
-	rxNum    = regexp.MustCompile(`(?i)\b([1-9][0-9]?)\b`) // 1..99
-	rxBool   = regexp.MustCompile(`(?i)\b(true|false|ya|tidak|benar|salah)\b`)
-	rxFinal  = regexp.MustCompile(`(?i)\b(final|jawaban|answer)\b[:：]?\s*([A-Z]|[1-9][0-9]?|true|false|ya|tidak|benar|salah)\b`)
+	rxLetter   = regexp.MustCompile(`\b[A-Z]\b`)             // a standalone capital A..Z
+	rxNum      = regexp.MustCompile(`(?i)\b([1-9][0-9]?)\b`) // 1..99
+	rxWordNext = regexp.MustCompile(`^[ \t]+\pL`)
 )
 
-func parseSimpleFinal(s string) string {
-	if m := rxFinal.FindStringSubmatch(s); len(m) > 2 {
-		return normalizeToken(m[2])
+// loneLetters lists the standalone capitals of s once each, in order. The
+// s of "IT'S" is no answer, nor are "A" and "I" followed by a word: those
+// are the article and the pronoun ("A good question", "I think").
+func loneLetters(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range rxLetter.FindAllStringIndex(s, -1) {
+		before, _ := utf8.DecodeLastRuneInString(s[:m[0]])
+		after, _ := utf8.DecodeRuneInString(s[m[1]:])
+		if inWord(before) || inWord(after) {
+			continue
+		}
+		l := s[m[0]:m[1]]
+		if (l == "A" || l == "I") && rxWordNext.MatchString(s[m[1]:]) {
+			continue
+		}
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// inWord reports whether r joins the letters around it into one word.
+func inWord(r rune) bool {
+	return r == '\'' || r == '’' || unicode.IsLetter(r)
+}
+
+// parseSimpleFinal looks for a marked final answer, then for the first
+// boolean word, lone capital letter or number; nothing found leaves the
+// reply to the raw fallback. It returns the answer with the
+// confidence and warnings of the way it was found.
+func parseSimpleFinal(s string, kw *parseRx) (string, float64, []string) {
+	if m := kw.final.FindStringSubmatch(s); len(m) > 2 {
+		return normalizeToken(m[2], kw), 0.6, nil
 	}
 	// fallback: get the first token that looks valid
-	if m := rxBool.FindStringSubmatch(s); len(m) > 1 {
-		return normalizeToken(m[1])
+	if m := kw.boolean.FindStringSubmatch(s); len(m) > 1 {
+		return normalizeToken(m[1], kw), 0.4, []string{"answer guessed from a true/false word"}
 	}
-	if m := rxLetter.FindStringSubmatch(s); len(m) > 1 {
-		return strings.ToUpper(m[1])
+	if c := loneLetters(s); len(c) > 0 {
+		warn := []string{"answer guessed from a lone letter"}
+		if len(c) > 1 {
+			warn = append(warn, "several candidate letters: "+strings.Join(c, ", "))
+		}
+		return c[0], 0.25, warn
 	}
-	if m := rxNum.FindStringSubmatch(s); len(m) > 1 {
-		return m[1]
+	if m := rxNum.FindAllStringSubmatch(s, -1); len(m) > 0 {
+		warn := []string{"answer guessed from a lone number"}
+		if c := distinct(m, strings.TrimSpace); len(c) > 1 {
+			warn = append(warn, "several candidate numbers: "+strings.Join(c, ", "))
+		}
+		return m[0][1], 0.25, warn
 	}
-	return ""
+	return "", 0, nil
 }
 
-func normalizeToken(t string) string {
+// distinct lists the first submatch of every match once, in order.
+func distinct(matches [][]string, norm func(string) string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if v := norm(m[1]); !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// normalizeToken writes booleans and letters one way; numbers are left to
+// parsed, which writes the answers of every strategy the same way.
+func normalizeToken(t string, kw *parseRx) string {
 	t = strings.TrimSpace(strings.ToLower(t))
-	switch t {
-	case "true", "ya", "benar":
+	switch {
+	case kw.truthy[t]:
 		return "True"
-	case "false", "tidak", "salah":
+	case kw.falsy[t]:
 		return "False"
 	default:
 		// A..Z or numbers
@@ -197,6 +393,9 @@ func normalizeToken(t string) string {
 func truncateSingleLine(s string, max int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
 	if len(s) > max {
+		for max > 0 && !utf8.RuneStart(s[max]) {
+			max--
+		}
 		return s[:max] + "…"
 	}
 	return s
@@ -0,0 +1,182 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/emandor/lemme_service/internal/lang"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/replies")
+
+// parseResult is what the golden files record of a parsed reply.
+type parseResult struct {
+	Number     int           `json:"number,omitempty"`
+	Mode       string        `json:"mode,omitempty"`
+	Confidence float64       `json:"confidence,omitempty"`
+	Answer     string        `json:"answer"`
+	Reason     string        `json:"reason,omitempty"`
+	Parts      []parseResult `json:"parts,omitempty"`
+	Warnings   []string      `json:"warnings,omitempty"`
+	Unparsed   bool          `json:"unparsed,omitempty"`
+}
+
+func resultOf(ans Answer, err error) parseResult {
+	r := parseResult{
+		Mode:       ans.ParseMode,
+		Confidence: ans.ParseConfidence,
+		Answer:     ans.Answer,
+		Reason:     ans.Reason,
+		Warnings:   ans.ParseWarnings,
+		Unparsed:   errors.Is(err, ErrUnparsed),
+	}
+	for _, p := range ans.Parts {
+		r.Parts = append(r.Parts, parseResult{Number: p.Number, Answer: p.Answer, Reason: p.Reason})
+	}
+	return r
+}
+
+// TestParseGolden parses every reply of testdata/replies/*.txt, real model
+// output of each shape, and compares the result with <name>.json next to it.
+func TestParseGolden(t *testing.T) {
+	replies, err := filepath.Glob(filepath.Join("testdata", "replies", "*.txt"))
+	if err != nil || len(replies) == 0 {
+		t.Fatalf("no replies: %v", err)
+	}
+	for _, path := range replies {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			reply, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(resultOf(TryParseAnswer(string(reply))), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(path, ".txt") + ".json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parsed %s as\n%s\nwant\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestTryParseAnswerLang(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		in     string
+		mode   string
+		answer string
+		reason string
+	}{
+		{"json", lang.English, `{"answer":" c ","reason":"r"}`, ParseJSON, "c", "r"},
+		{"json without answer", lang.English, `{"reason":"because"} so B`, ParseKeyword, "B", "because"},
+		{"json array", "", `[{"answer":"A"},{"answer":"B"}]`, ParseJSON, "1: A; 2: B", ""},
+		{"fenced", "", "```json\n{\"answer\":\"A\"}\n```", ParseJSONFenced, "A", ""},
+		{"fenced array without tag", "", "```\n[{\"number\":2,\"answer\":\"D\"}]\n```", ParseJSONFenced, "2: D", ""},
+		{"extracted", "", `So: {"answer":"E"}.`, ParseJSONExtracted, "E", ""},
+		{"object after array", "", `Options [A, B] -> {"answer":"B"}`, ParseJSONExtracted, "B", ""},
+		{"colon", lang.English, "Answer: 12 cm\nReason: by Pythagoras", ParseColon, "12 cm", "by Pythagoras"},
+		{"fullwidth colon", lang.Indonesian, "Jawaban：B", ParseColon, "B", ""},
+		{"indonesian colon with english keywords", lang.Indonesian, "Answer: A\nAlasan: r", ParseColon, "A", "r"},
+		{"colon of another language", lang.English, "Jawaban: tiga", ParseRaw, "Jawaban: tiga", ""},
+		{"final marker", lang.English, "so x = 2.5 - 5.\nFinal: -2.5", ParseKeyword, "-2.5", ""},
+		{"indonesian marker", lang.Indonesian, "jadi jawaban nya... jawaban c", ParseKeyword, "C", ""},
+		{"indonesian boolean", lang.Indonesian, "Itu salah.", ParseKeyword, "False", ""},
+		{"boolean of another language", lang.English, "Itu salah.", ParseRaw, "Itu salah.", ""},
+		{"all languages", "", "Itu salah.", ParseKeyword, "False", ""},
+		{"english boolean in indonesian", lang.Indonesian, "That is TRUE.", ParseKeyword, "True", ""},
+		{"pronoun skipped", lang.English, "I pick D.", ParseKeyword, "D", ""},
+		{"contraction skipped", lang.English, "It's D, isn't it", ParseKeyword, "D", ""},
+		{"article in prose", lang.English, "This is a good question, the capital is Paris", ParseRaw, "This is a good question, the capital is Paris", ""},
+		{"capital article skipped", lang.English, "A good pick here is C.", ParseKeyword, "C", ""},
+		{"option A at the end", lang.English, "Looking at the map, option A.", ParseKeyword, "A", ""},
+		{"lowercase letter", lang.English, "maybe b", ParseRaw, "maybe b", ""},
+		{"unknown language", "xx", "Jawaban: A", ParseColon, "A", ""},
+		{"raw", lang.English, "  no idea  ", ParseRaw, "no idea", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ans, err := TryParseAnswerLang(tt.in, tt.code)
+			if ans.ParseMode != tt.mode || ans.Answer != tt.answer || ans.Reason != tt.reason {
+				t.Errorf("got %s %q (reason %q), want %s %q (reason %q); warnings %v",
+					ans.ParseMode, ans.Answer, ans.Reason, tt.mode, tt.answer, tt.reason, ans.ParseWarnings)
+			}
+			if (tt.mode == ParseRaw) != errors.Is(err, ErrUnparsed) {
+				t.Errorf("err = %v", err)
+			}
+			if ans.Raw != strings.TrimSpace(tt.in) {
+				t.Errorf("raw = %q", ans.Raw)
+			}
+		})
+	}
+}
+
+func TestParseConfidence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		warn string
+	}{
+		{`{"answer":"A"}`, 0.95, ""},
+		{"```json\n{\"answer\":\"A\"}\n```", 0.9, ""},
+		{`x {"answer":"A"} y`, 0.75, "surrounded by other text"},
+		{"Answer: A", 0.6, ""},
+		{"so the answer A", 0.6, ""},
+		{"it is true", 0.4, "true/false word"},
+		{"between C and D, C", 0.25, "several candidate letters: C, D"},
+		{"about 12 or 13", 0.25, "several candidate numbers: 12, 13"},
+		{"unsure", 0, "raw reply is used"},
+		{strings.Repeat("unsure ", 100), 0, "truncated to 500 bytes"},
+		{`[{"number":1,"answer":"A"},{"number":1,"answer":"B"}]`, 0.95, "question 1 answered more than once"},
+	}
+	for _, tt := range tests {
+		ans, _ := TryParseAnswer(tt.in)
+		if ans.ParseConfidence != tt.want {
+			t.Errorf("%.20q: confidence %v (%s), want %v", tt.in, ans.ParseConfidence, ans.ParseMode, tt.want)
+		}
+		warnings := strings.Join(ans.ParseWarnings, "\n")
+		if (tt.warn == "") != (warnings == "") || !strings.Contains(warnings, tt.warn) {
+			t.Errorf("%.20q: warnings %q, want %q", tt.in, warnings, tt.warn)
+		}
+	}
+	for mode := range parseConfidence {
+		if c := parseConfidence[mode]; c < 0 || c > 1 {
+			t.Errorf("%s: confidence %v out of 0..1", mode, c)
+		}
+	}
+}
+
+func TestUnparsedFallback(t *testing.T) {
+	long := strings.Repeat("é", maxRawAnswer)
+	ans, err := TryParseAnswer("line one\nline two: " + long)
+	if !errors.Is(err, ErrUnparsed) || ans.ParseMode != ParseRaw {
+		t.Fatalf("err %v, mode %s", err, ans.ParseMode)
+	}
+	if strings.Contains(ans.Answer, "\n") || !utf8.ValidString(ans.Answer) || !strings.HasSuffix(ans.Answer, "…") || len(ans.Answer) > maxRawAnswer+len("…") {
+		t.Errorf("answer %q", ans.Answer)
+	}
+	if !strings.HasPrefix(ans.Raw, "line one\nline two") {
+		t.Errorf("raw %q", ans.Raw)
+	}
+}
@@ -1,41 +1,54 @@
 package providers
 
-import (
-	"strings"
-)
+import "strings"
 
 // instruction to make all LLMs reply in single-line JSON, no code fence.
 const JSON_INSTRUCTION = `Return ONLY a single-line JSON object with keys:
 "answer": string (may be "A".."Z", number like "2", boolean "True"/"False", or free text),
 "reason": string (optional, brief).
-No Markdown, no code fences, no extra text. please use Indonesian if the question is in Indonesian.`
+No Markdown, no code fences, no extra text.`
 
 // BuildPromptWithChoices: user OCR text + optional choices to build prompt.
-// if choices is empty or nil, it will be ignored.
+// if choices is empty or nil, it will be ignored. It renders the builtin
+// template; configured templates are picked by the quiz service.
 func BuildPromptWithChoices(ocr string, choices []string) string {
-	var b strings.Builder
-	b.WriteString(JSON_INSTRUCTION)
-	b.WriteString("\n\nSolve this quiz based on the OCR text below.\n")
-	if len(choices) > 0 {
-		b.WriteString("If appropriate, select ONLY ONE from these options:\n")
-		for i, c := range choices {
-			// show as A if letter, else just show the text
-			b.WriteString("- ")
-			// if there is "A/B/C..." just show as is
-			b.WriteString(c)
-			if i < len(choices)-1 {
-				b.WriteByte('\n')
-			}
-		}
-		b.WriteString("\n")
-	}
-	b.WriteString("\nOCR:\n")
-	b.WriteString(ocr)
-	b.WriteString("\n")
-	return b.String()
+	out, _ := BuiltinPrompt(PromptSingle).Render(PromptVars{OCR: ocr, Choices: choices})
+	return out
+}
+
+// JSON_MULTI_INSTRUCTION asks for one answer per numbered question.
+const JSON_MULTI_INSTRUCTION = `Return ONLY a single-line JSON object with the key "answers": an array with one object per question, in question order, each with keys:
+"number": integer (the question number),
+"answer": string (may be "A".."Z", number like "2", boolean "True"/"False", or free text),
+"reason": string (optional, brief).
+No Markdown, no code fences, no extra text.`
+
+// BuildMultiPrompt: OCR text holding several numbered questions; every
+// number in numbers must be answered.
+func BuildMultiPrompt(ocr string, numbers []int) string {
+	out, _ := BuiltinPrompt(PromptMulti).Render(PromptVars{OCR: ocr, Numbers: numbers})
+	return out
 }
 
 // BuildPrompt: user OCR text only, no choices.
 func BuildPrompt(ocr string) string {
 	return BuildPromptWithChoices(ocr, nil)
 }
+
+// maxTitleInput caps how much OCR text is sent for title generation.
+const maxTitleInput = 2000
+
+// BuildTitlePrompt asks for a short plain-text title of the quiz, for
+// Completer.Complete.
+func BuildTitlePrompt(ocr string) string {
+	if r := []rune(ocr); len(r) > maxTitleInput {
+		ocr = string(r[:maxTitleInput])
+	}
+	var b strings.Builder
+	b.WriteString(`Reply with ONLY a short title (max 8 words) describing the topic of the quiz below, on one line.
+No quotes, no Markdown, no extra text. Use the same language as the quiz.`)
+	b.WriteString("\n\nOCR:\n")
+	b.WriteString(ocr)
+	b.WriteString("\n")
+	return b.String()
+}
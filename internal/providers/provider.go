@@ -5,6 +5,8 @@ import (
 )
 
 type Answer struct {
+	// Number is the question number for a part of a multi-question answer.
+	Number     int            `json:"number,omitempty"`
 	Answer     string         `json:"answer"`
 	Reason     string         `json:"reason,omitempty"`
 	Options    []string       `json:"options,omitempty"`
@@ -13,6 +15,17 @@ type Answer struct {
 	Raw        string         `json:"raw,omitempty"`
 	LatencyMs  int            `json:"latency_ms,omitempty"`
 	TokenUsage map[string]any `json:"token_usage,omitempty"`
+	// Reasoning is the model's reasoning summary or thoughts, kept apart
+	// from Raw so that it is never parsed for the answer.
+	Reasoning string `json:"reasoning,omitempty"`
+	// ParseMode tells how the answer was read from the reply (Parse*),
+	// ParseConfidence how much that strategy can be trusted (0..1) and
+	// ParseWarnings what looked off while reading it.
+	ParseMode       string   `json:"parse_mode,omitempty"`
+	ParseConfidence float64  `json:"parse_confidence,omitempty"`
+	ParseWarnings   []string `json:"parse_warnings,omitempty"`
+	// Parts holds one answer per question when several were asked at once.
+	Parts []Answer `json:"parts,omitempty"`
 }
 
 type SourceName string
@@ -0,0 +1,267 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// Parse modes recorded with every answer.
+const (
+	// ParseStructured: provider-native structured output that validated
+	// against the answer schema.
+	ParseStructured = "structured"
+	// ParseJSON: a plain JSON reply.
+	ParseJSON = "json"
+	// ParseJSONFenced: JSON inside a ``` code fence.
+	ParseJSONFenced = "json_fenced"
+	// ParseJSONExtracted: the first JSON value found in prose.
+	ParseJSONExtracted = "json_extracted"
+	// ParseColon: "Answer: ..." / "Jawaban: ..." lines.
+	ParseColon = "colon"
+	// ParseKeyword: a lone letter, number or true/false word.
+	ParseKeyword = "keyword"
+	// ParseRaw: nothing matched; the raw text is the answer.
+	ParseRaw = "raw"
+)
+
+// answerToolName is the tool Anthropic is forced to call with the answer.
+const answerToolName = "submit_answer"
+
+var answerItem = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"answer": map[string]any{
+			"type":        "string",
+			"description": `Option letter ("A".."Z"), number, "True"/"False", or free text.`,
+		},
+		"reason": map[string]any{
+			"type":        "string",
+			"description": "Brief explanation.",
+		},
+	},
+	"required":             []any{"answer", "reason"},
+	"additionalProperties": false,
+}
+
+// singleSchema is the JSON Schema of a single answer.
+var singleSchema = answerItem
+
+// multiSchema wraps one answer per question; structured-output modes need
+// an object at the top level.
+var multiSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"answers": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"number": map[string]any{"type": "integer", "description": "The question number."},
+					"answer": answerItem["properties"].(map[string]any)["answer"],
+					"reason": answerItem["properties"].(map[string]any)["reason"],
+				},
+				"required":             []any{"number", "answer", "reason"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []any{"answers"},
+	"additionalProperties": false,
+}
+
+// AnswerSchema returns the shared answer JSON Schema for a prompt kind.
+func AnswerSchema(kind PromptKind) map[string]any {
+	if kind == PromptMulti {
+		return multiSchema
+	}
+	return singleSchema
+}
+
+// geminiSchema converts a JSON Schema to Gemini's OpenAPI subset: upper
+// case types and no additionalProperties.
+func geminiSchema(s map[string]any) map[string]any {
+	out := make(map[string]any, len(s))
+	for k, v := range s {
+		switch k {
+		case "additionalProperties":
+			continue
+		case "type":
+			out[k] = strings.ToUpper(v.(string))
+		case "items":
+			out[k] = geminiSchema(v.(map[string]any))
+		case "properties":
+			props := map[string]any{}
+			for name, p := range v.(map[string]any) {
+				props[name] = geminiSchema(p.(map[string]any))
+			}
+			out[k] = props
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// ValidateSchema checks v (as decoded by encoding/json) against the subset
+// of JSON Schema used here: type, properties, required,
+// additionalProperties=false and items.
+func ValidateSchema(schema map[string]any, v any) error {
+	return validate(schema, v, "$")
+}
+
+func validate(schema map[string]any, v any, path string) error {
+	switch t, _ := schema["type"].(string); t {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object", path)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for _, r := range schemaStrings(schema["required"]) {
+			if _, ok := obj[r]; !ok {
+				return fmt.Errorf("%s: missing %q", path, r)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				if ap, set := schema["additionalProperties"].(bool); set && !ap {
+					return fmt.Errorf("%s: unexpected %q", path, k)
+				}
+				continue
+			}
+			if err := validate(ps, obj[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array", path)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, it := range arr {
+				if err := validate(items, it, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: want string", path)
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) {
+			return fmt.Errorf("%s: want integer", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: want number", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean", path)
+		}
+	}
+	return nil
+}
+
+func schemaStrings(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if s, ok := s.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseStructured decodes a structured-output reply and validates it
+// against the schema of kind; numbers are read in the decimals of language
+// code.
+func parseStructured(text string, kind PromptKind, code string) (Answer, error) {
+	ans := Answer{Raw: strings.TrimSpace(text)}
+	var v any
+	if err := json.Unmarshal([]byte(ans.Raw), &v); err != nil {
+		return Answer{}, err
+	}
+	if arr, ok := v.([]any); ok && kind == PromptMulti {
+		// the bare list of answers, as plain prompts used to ask for
+		v = map[string]any{"answers": arr}
+	}
+	if err := ValidateSchema(AnswerSchema(kind), v); err != nil {
+		return Answer{}, err
+	}
+
+	obj := v.(map[string]any)
+	if kind != PromptMulti {
+		ans.Answer, ans.Reason = str(obj["answer"]), str(obj["reason"])
+		if strings.TrimSpace(ans.Answer) == "" {
+			return Answer{}, fmt.Errorf("empty answer")
+		}
+		return parsed(ans, ParseStructured, parserFor(code)), nil
+	}
+	items, _ := json.Marshal(obj["answers"])
+	if !tryJSONArray(string(items), &ans) {
+		return Answer{}, fmt.Errorf("no usable answers")
+	}
+	return parsed(ans, ParseStructured, parserFor(code)), nil
+}
+
+// parseOutput turns a model reply into an Answer: strictly when the reply
+// came from a structured-output mode, else (or when that fails) through
+// the heuristics of TryParseAnswerLang.
+func parseOutput(ctx context.Context, name SourceName, text string, structured bool) Answer {
+	if structured {
+		ans, err := parseStructured(text, PromptKindFrom(ctx), LanguageFrom(ctx))
+		if err == nil {
+			return ans
+		}
+		log := telemetry.L()
+		log.Warn().Err(err).Str("provider", string(name)).Msg("structured_output_invalid")
+		ans, perr := TryParseAnswerLang(text, LanguageFrom(ctx))
+		ans.ParseWarnings = append([]string{"structured output invalid: " + err.Error()}, ans.ParseWarnings...)
+		logUnparsed(name, ans, perr)
+		return ans
+	}
+	ans, err := TryParseAnswerLang(text, LanguageFrom(ctx))
+	logUnparsed(name, ans, err)
+	return ans
+}
+
+func logUnparsed(name SourceName, ans Answer, err error) {
+	if err != nil {
+		log := telemetry.L()
+		log.Warn().Err(err).Str("provider", string(name)).Int("raw_len", len(ans.Raw)).Msg("answer_unparsed")
+	}
+}
+
+type kindKey struct{}
+
+// WithPromptKind tells the providers which answer schema the prompt asks
+// for.
+func WithPromptKind(ctx context.Context, kind PromptKind) context.Context {
+	return context.WithValue(ctx, kindKey{}, kind)
+}
+
+// PromptKindFrom returns the kind set by WithPromptKind, or PromptSingle.
+func PromptKindFrom(ctx context.Context) PromptKind {
+	if kind, ok := ctx.Value(kindKey{}).(PromptKind); ok {
+		return kind
+	}
+	return PromptSingle
+}
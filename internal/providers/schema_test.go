@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMultiInstructionMatchesSchema(t *testing.T) {
+	if !strings.Contains(JSON_MULTI_INSTRUCTION, `"answers"`) {
+		t.Fatalf("multi instruction does not ask for the answers object:\n%s", JSON_MULTI_INSTRUCTION)
+	}
+	prompt := BuildMultiPrompt("1. a?\n2. b?", []int{1, 2})
+	if !strings.Contains(prompt, JSON_MULTI_INSTRUCTION) {
+		t.Fatalf("builtin multi prompt lacks the instruction:\n%s", prompt)
+	}
+}
+
+func TestParseOutputMulti(t *testing.T) {
+	ctx := WithPromptKind(context.Background(), PromptMulti)
+	tests := []struct {
+		name       string
+		text       string
+		structured bool
+		mode       string
+	}{
+		{"structured object", `{"answers":[{"number":1,"answer":"A","reason":"r"},{"number":2,"answer":"C","reason":"r"}]}`, true, ParseStructured},
+		{"structured bare array", `[{"number":1,"answer":"A","reason":"r"},{"number":2,"answer":"C","reason":"r"}]`, true, ParseStructured},
+		{"plain object", `{"answers":[{"number":1,"answer":"A"},{"number":2,"answer":"C"}]}`, false, ParseJSON},
+		{"plain bare array", `[{"number":1,"answer":"A"},{"number":2,"answer":"C"}]`, false, ParseJSON},
+		{"fenced object", "```json\n{\"answers\":[{\"number\":1,\"answer\":\"A\"},{\"number\":2,\"answer\":\"C\"}]}\n```", false, ParseJSONFenced},
+		{"object in prose", `Here you go: {"answers":[{"number":1,"answer":"A"},{"number":2,"answer":"C"}]}`, false, ParseJSONExtracted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ans := parseOutput(ctx, SourceOpenAI, tt.text, tt.structured)
+			if ans.ParseMode != tt.mode {
+				t.Errorf("mode = %q, want %q (warnings %v)", ans.ParseMode, tt.mode, ans.ParseWarnings)
+			}
+			if ans.Answer != "1: A; 2: C" || len(ans.Parts) != 2 {
+				t.Errorf("answer = %q, parts = %+v", ans.Answer, ans.Parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// Streamer is implemented by providers that can stream their answer. onDelta
+// receives text fragments as they arrive; the returned Answer is parsed from
+// the complete text, exactly as Ask would.
+type Streamer interface {
+	Stream(ctx context.Context, prompt string, onDelta func(string)) (Answer, error)
+}
+
+// maxSSELine bounds a single SSE line; Gemini chunks carry whole JSON
+// responses.
+const maxSSELine = 1 << 20
+
+// readSSE calls fn for every server-sent event in r until EOF, or until fn
+// returns errStopSSE.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxSSELine)
+
+	var event string
+	var data []string
+	flush := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", data[:0]
+		return err
+	}
+
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+var errStopSSE = errors.New("stop")
+
+// sseDelta is what one server-sent event adds to a streamed reply.
+type sseDelta struct {
+	Text, Reasoning string
+	// Done ends the stream.
+	Done bool
+}
+
+// streamText runs req, feeds every text fragment extracted by delta to
+// onDelta and returns the parsed answer of the whole text; structured says
+// the text is structured output. Reasoning fragments are collected into
+// Answer.Reasoning and not relayed.
+func streamText(t *Transport, req *http.Request, name SourceName, structured bool, onDelta func(string), delta func(event, data string) (sseDelta, error)) (Answer, error) {
+	log := telemetry.L().With().Str("provider", string(name)).Logger()
+	req.Header.Set("Accept", "text/event-stream")
+
+	t0 := time.Now()
+	resp, err := doHTTP(t, req)
+	if err != nil {
+		log.Error().Err(err).Msg("stream_request_failed")
+		return Answer{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		log.Error().Str("status", resp.Status).Str("body", string(raw)).Msg("stream_http_error")
+		return Answer{}, errors.New(strings.ToLower(string(name)) + " http " + resp.Status)
+	}
+
+	var text, reasoning strings.Builder
+	err = readSSE(resp.Body, func(event, data string) error {
+		d, err := delta(event, data)
+		if err != nil {
+			return err
+		}
+		reasoning.WriteString(d.Reasoning)
+		if d.Text != "" {
+			text.WriteString(d.Text)
+			if onDelta != nil {
+				onDelta(d.Text)
+			}
+		}
+		if d.Done {
+			return errStopSSE
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopSSE) {
+		if errors.Is(err, ErrMaxTokens) {
+			log.Warn().Msg("stream_max_tokens")
+		}
+		return Answer{}, err
+	}
+
+	full := strings.TrimSpace(text.String())
+	if full == "" {
+		return Answer{}, errors.New(strings.ToLower(string(name)) + ": empty stream")
+	}
+	parsed := parseOutput(req.Context(), name, full, structured)
+	parsed.Reasoning = strings.TrimSpace(reasoning.String())
+	parsed.LatencyMs = int(time.Since(t0) / time.Millisecond)
+	return parsed, nil
+}
+
+// dryRunStream replays a simulated answer as a few deltas.
+func dryRunStream(ans Answer, onDelta func(string)) (Answer, error) {
+	if onDelta != nil {
+		b, _ := json.Marshal(map[string]string{"answer": ans.Answer, "reason": ans.Reason})
+		for s := string(b); s != ""; {
+			n := min(len(s), 8)
+			onDelta(s[:n])
+			s = s[n:]
+		}
+	}
+	return ans, nil
+}
+
+func (c *OpenAI) Stream(ctx context.Context, prompt string, onDelta func(string)) (Answer, error) {
+	if c.DryRun {
+		ans, _ := c.Ask(ctx, prompt)
+		return dryRunStream(ans, onDelta)
+	}
+	req, _ := c.newRequest(ctx, prompt, true)
+	return streamText(c.HTTP, req, c.Name(), c.Structured, onDelta, func(event, data string) (sseDelta, error) {
+		var ev struct {
+			Type     string `json:"type"`
+			Delta    string `json:"delta"`
+			Response struct {
+				IncompleteDetails *struct {
+					Reason string `json:"reason"`
+				} `json:"incomplete_details"`
+			} `json:"response"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal([]byte(data), &ev) != nil {
+			return sseDelta{}, nil
+		}
+		switch ev.Type {
+		case "response.output_text.delta":
+			return sseDelta{Text: ev.Delta}, nil
+		case "response.reasoning_summary_text.delta":
+			return sseDelta{Reasoning: ev.Delta}, nil
+		case "response.reasoning_summary_part.done":
+			return sseDelta{Reasoning: "\n\n"}, nil
+		case "response.incomplete":
+			if d := ev.Response.IncompleteDetails; d != nil && d.Reason == "max_output_tokens" {
+				return sseDelta{Done: true}, maxTokensError(c.Name())
+			}
+			return sseDelta{Done: true}, nil
+		case "response.completed":
+			return sseDelta{Done: true}, nil
+		case "response.failed", "error":
+			msg := "openai stream failed"
+			if ev.Error != nil {
+				msg += ": " + ev.Error.Message
+			}
+			return sseDelta{Done: true}, errors.New(msg)
+		}
+		return sseDelta{}, nil
+	})
+}
+
+func (c *Anthropic) Stream(ctx context.Context, prompt string, onDelta func(string)) (Answer, error) {
+	if c.DryRun {
+		ans, _ := c.Ask(ctx, prompt)
+		return dryRunStream(ans, onDelta)
+	}
+	req := c.newRequest(ctx, prompt, true)
+	return streamText(c.HTTP, req, c.Name(), c.Structured, onDelta, func(event, data string) (sseDelta, error) {
+		var ev struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				Thinking    string `json:"thinking"`
+				PartialJSON string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal([]byte(data), &ev) != nil {
+			return sseDelta{}, nil
+		}
+		switch ev.Type {
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				return sseDelta{Text: ev.Delta.Text}, nil
+			case "thinking_delta":
+				return sseDelta{Reasoning: ev.Delta.Thinking}, nil
+			case "input_json_delta":
+				// the answer tool's input, streamed as JSON fragments
+				return sseDelta{Text: ev.Delta.PartialJSON}, nil
+			}
+		case "message_delta":
+			if ev.Delta.StopReason == "max_tokens" {
+				return sseDelta{Done: true}, maxTokensError(c.Name())
+			}
+		case "message_stop":
+			return sseDelta{Done: true}, nil
+		case "error":
+			msg := "anthropic stream failed"
+			if ev.Error != nil {
+				msg += ": " + ev.Error.Message
+			}
+			return sseDelta{Done: true}, errors.New(msg)
+		}
+		return sseDelta{}, nil
+	})
+}
+
+func (c *Gemini) Stream(ctx context.Context, prompt string, onDelta func(string)) (Answer, error) {
+	if c.DryRun {
+		ans, _ := c.Ask(ctx, prompt)
+		return dryRunStream(ans, onDelta)
+	}
+	req, _, err := c.newRequest(ctx, prompt, true)
+	if err != nil {
+		return Answer{}, err
+	}
+	return streamText(c.HTTP, req, c.Name(), c.Structured, onDelta, func(_, data string) (sseDelta, error) {
+		var chunk struct {
+			Candidates []struct {
+				Content struct {
+					Parts []geminiPart `json:"parts"`
+				} `json:"content"`
+				FinishReason string `json:"finishReason"`
+			} `json:"candidates"`
+			PromptFeedback *struct {
+				BlockReason string `json:"blockReason"`
+			} `json:"promptFeedback"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			return sseDelta{}, nil
+		}
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			return sseDelta{Done: true}, errors.New("gemini blocked: " + chunk.PromptFeedback.BlockReason)
+		}
+		var d sseDelta
+		for _, cand := range chunk.Candidates {
+			if cand.FinishReason == "MAX_TOKENS" {
+				return sseDelta{Done: true}, maxTokensError(c.Name())
+			}
+			text, thoughts := splitGeminiParts(cand.Content.Parts)
+			d.Text += text
+			d.Reasoning += thoughts
+		}
+		return d, nil
+	})
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/emandor/lemme_service/internal/lang"
+)
+
+// PromptKind selects which prompt a template renders.
+type PromptKind string
+
+const (
+	// PromptSingle answers the quiz as one question.
+	PromptSingle PromptKind = "single"
+	// PromptMulti answers every numbered question (see JSON_MULTI_INSTRUCTION).
+	PromptMulti PromptKind = "multi"
+)
+
+// BuiltinPromptVersion is the version recorded for the compiled-in prompts.
+const BuiltinPromptVersion = "builtin-3"
+
+// PromptVars are the variables available to prompt templates.
+type PromptVars struct {
+	OCR      string
+	LaTeX    string   // OCR with formulas as LaTeX, math OCR mode only
+	Choices  []string // "A. text", empty when none were detected
+	Numbers  []int    // question numbers, multi prompts only
+	Language string   // detected quiz language, e.g. "ind" or "eng"; may be empty
+	Subject  string   // set by the user on upload; may be empty
+	Images   int      // images sent with the prompt (vision and hybrid modes)
+	// AnswerLanguage is the user's preferred answer language; empty
+	// means the language of the quiz.
+	AnswerLanguage string
+}
+
+// PromptTemplate is one version of a prompt. Weight is its share in the
+// A/B assignment; 0 keeps it loaded but unused. Lang restricts it to quizzes
+// in that language; "" serves every language without its own template.
+type PromptTemplate struct {
+	Kind    PromptKind
+	Version string
+	Lang    string
+	Weight  int
+	tmpl    *template.Template
+}
+
+var promptFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"numbers":  joinInts,
+	"langName": lang.Name,
+}
+
+// ParsePromptTemplate compiles body (Go text/template syntax).
+func ParsePromptTemplate(kind PromptKind, version, body string, weight int) (*PromptTemplate, error) {
+	if kind != PromptSingle && kind != PromptMulti {
+		return nil, fmt.Errorf("unknown prompt kind %q", kind)
+	}
+	t, err := template.New(string(kind) + "/" + version).Funcs(promptFuncs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptTemplate{Kind: kind, Version: version, Weight: weight, tmpl: t}, nil
+}
+
+func (t *PromptTemplate) Render(v PromptVars) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// PickPrompt assigns key (a quiz id) to one of ts by weight. The same key
+// always gets the same version while the set does not change. It returns
+// nil when no template has a positive weight.
+func PickPrompt(ts []*PromptTemplate, key int64) *PromptTemplate {
+	total := 0
+	for _, t := range ts {
+		total += max(t.Weight, 0)
+	}
+	if total == 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatInt(key, 10)))
+	n := int(h.Sum32() % uint32(total))
+	for _, t := range ts {
+		if n -= max(t.Weight, 0); n < 0 {
+			return t
+		}
+	}
+	return nil
+}
+
+// languageInstruction asks for the reason in the preferred language, or
+// else in the language of the quiz.
+const languageInstruction = `
+{{- if .AnswerLanguage}} Write the reason in {{langName .AnswerLanguage}}.
+{{- else if .Language}} The quiz is in {{langName .Language}}; write the reason in {{langName .Language}}.
+{{- else}} Write the reason in the language of the quiz.
+{{- end}}`
+
+// quizSource names what the quiz is read from: OCR text, attached images
+// or both.
+const quizSource = `{{if not .Images}}the OCR text below
+{{- else}}the attached image{{if gt .Images 1}}s{{end}}{{if .OCR}} and the OCR text below{{end}}
+{{- end}}`
+
+// ocrSection shows the LaTeX variant of the OCR when there is one; it is
+// left out when only images are sent.
+const ocrSection = `
+{{- if .LaTeX}}
+
+OCR (formulas in LaTeX):
+{{.LaTeX}}
+{{- else if .OCR}}
+
+OCR:
+{{.OCR}}
+{{- end}}
+`
+
+const builtinSingle = JSON_INSTRUCTION + languageInstruction + `
+
+Solve this quiz based on ` + quizSource + `.
+{{- if .Choices}}
+If appropriate, select ONLY ONE from these options:
+{{- range .Choices}}
+- {{.}}
+{{- end}}
+{{- end}}` + ocrSection
+
+const builtinMulti = JSON_MULTI_INSTRUCTION + languageInstruction + `
+
+Solve every quiz question in ` + quizSource + `. Questions to answer: {{numbers .Numbers}}.` + ocrSection
+
+// BuiltinPrompt returns the compiled-in template of kind.
+func BuiltinPrompt(kind PromptKind) *PromptTemplate {
+	if kind == PromptMulti {
+		return builtinMultiTemplate
+	}
+	return builtinSingleTemplate
+}
+
+var (
+	builtinSingleTemplate = mustPrompt(PromptSingle, builtinSingle)
+	builtinMultiTemplate  = mustPrompt(PromptMulti, builtinMulti)
+)
+
+func mustPrompt(kind PromptKind, body string) *PromptTemplate {
+	t, err := ParsePromptTemplate(kind, BuiltinPromptVersion, body, 1)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,339 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// TransportConfig tunes the shared provider HTTP transport.
+type TransportConfig struct {
+	Timeout          time.Duration // per attempt; streams only until headers
+	RPS              float64
+	Burst            int
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int           // consecutive failures that open the breaker
+	BreakerCooldown  time.Duration // how long it stays open before a probe
+}
+
+func (c *TransportConfig) defaults() {
+	if c.Timeout <= 0 {
+		c.Timeout = 60 * time.Second
+	}
+	if c.RPS <= 0 {
+		c.RPS = 2
+	}
+	if c.Burst <= 0 {
+		c.Burst = 2
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = 250 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 10 * time.Second
+	}
+	if c.BreakerThreshold <= 0 {
+		c.BreakerThreshold = 5
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = 30 * time.Second
+	}
+}
+
+// Transport sends provider requests with rate limiting, retries on 429/5xx
+// and network errors (honouring Retry-After), and a circuit breaker.
+type Transport struct {
+	name    SourceName
+	cfg     TransportConfig
+	client  *http.Client
+	limiter *rate.Limiter
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	stats     TransportStats
+}
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// TransportStats is a snapshot of one provider transport.
+type TransportStats struct {
+	Provider    SourceName   `json:"provider"`
+	State       BreakerState `json:"state"`
+	Requests    int64        `json:"requests"`
+	Retries     int64        `json:"retries"`
+	Failures    int64        `json:"failures"`
+	Rejected    int64        `json:"rejected"`
+	LastError   string       `json:"last_error,omitempty"`
+	LastErrorAt *time.Time   `json:"last_error_at,omitempty"`
+	OpenUntil   *time.Time   `json:"open_until,omitempty"`
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Transport
+)
+
+// NewTransport creates the transport of one provider and registers it for
+// Stats.
+func NewTransport(name SourceName, cfg TransportConfig) *Transport {
+	cfg.defaults()
+	t := &Transport{
+		name:    name,
+		cfg:     cfg,
+		client:  &http.Client{},
+		limiter: rate.NewLimiter(rate.Limit(cfg.RPS), cfg.Burst),
+		state:   BreakerClosed,
+	}
+	registryMu.Lock()
+	registry = append(registry, t)
+	registryMu.Unlock()
+	return t
+}
+
+// Stats returns a snapshot of every registered provider transport.
+func Stats() []TransportStats {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	out := make([]TransportStats, 0, len(registry))
+	for _, t := range registry {
+		out = append(out, t.Stats())
+	}
+	return out
+}
+
+func (t *Transport) Stats() TransportStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.stats
+	s.Provider = t.name
+	s.State = t.state
+	if t.state == BreakerOpen {
+		until := t.openUntil
+		s.OpenUntil = &until
+	}
+	return s
+}
+
+// Do sends req, retrying when it is safe to. The request body must be
+// replayable (GetBody set), as it is for http.NewRequest with a bytes.Reader.
+//
+// Each attempt gets Timeout to return the whole response. A request that
+// accepts text/event-stream only gets it to return the headers: the stream
+// then lasts as long as req's context allows.
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	log := telemetry.L().With().Str("provider", string(t.name)).Logger()
+	ctx := req.Context()
+
+	if !t.allow() {
+		t.mu.Lock()
+		t.stats.Rejected++
+		t.mu.Unlock()
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= t.cfg.MaxRetries; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			t.abort()
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					t.abort()
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		t.mu.Lock()
+		t.stats.Requests++
+		t.mu.Unlock()
+
+		actx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(t.cfg.Timeout, cancel)
+		resp, err := t.client.Do(r.WithContext(actx))
+		var wait time.Duration
+		switch {
+		case err != nil:
+			timedOut := !timer.Stop()
+			cancel()
+			if ctx.Err() != nil {
+				// cancelled by the caller; not the provider's fault
+				t.abort()
+				return nil, err
+			}
+			if timedOut {
+				err = fmt.Errorf("%s: no response within %s: %w", t.name, t.cfg.Timeout, err)
+			}
+			lastErr = err
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			lastErr = errors.New(string(t.name) + " http " + resp.Status)
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			timer.Stop()
+			cancel()
+		default:
+			if isStream(req) {
+				timer.Stop()
+			}
+			resp.Body = &attemptBody{ReadCloser: resp.Body, stop: func() { timer.Stop(); cancel() }}
+			t.record(nil)
+			return resp, nil
+		}
+
+		if attempt == t.cfg.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			break
+		}
+		if wait <= 0 {
+			wait = t.backoff(attempt)
+		}
+		wait = min(wait, t.cfg.MaxBackoff)
+		log.Warn().Err(lastErr).Int("attempt", attempt+1).Dur("wait", wait).Msg("provider_retry")
+
+		t.mu.Lock()
+		t.stats.Retries++
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.abort()
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	t.record(lastErr)
+	return nil, lastErr
+}
+
+// isStream reports whether req asks for server-sent events.
+func isStream(req *http.Request) bool {
+	return req.Header.Get("Accept") == "text/event-stream"
+}
+
+// attemptBody releases the context of its attempt once closed.
+type attemptBody struct {
+	io.ReadCloser
+	stop func()
+}
+
+func (b *attemptBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.stop()
+	return err
+}
+
+// backoff is full-jitter exponential backoff.
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.cfg.BaseBackoff << attempt
+	if d <= 0 || d > t.cfg.MaxBackoff {
+		d = t.cfg.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// retryAfter parses a Retry-After header (seconds or HTTP date).
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// allow reports whether a request may go out, moving an open breaker whose
+// cooldown passed to half-open so that a single probe is let through.
+func (t *Transport) allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch t.state {
+	case BreakerOpen:
+		if time.Now().Before(t.openUntil) {
+			return false
+		}
+		t.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// a probe is already in flight
+		return false
+	}
+	return true
+}
+
+func (t *Transport) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		t.state, t.failures = BreakerClosed, 0
+		return
+	}
+
+	now := time.Now()
+	t.stats.Failures++
+	t.stats.LastError = err.Error()
+	t.stats.LastErrorAt = &now
+	t.failures++
+	if t.state == BreakerHalfOpen || t.failures >= t.cfg.BreakerThreshold {
+		t.state = BreakerOpen
+		t.openUntil = now.Add(t.cfg.BreakerCooldown)
+		log := telemetry.L()
+		log.Warn().Str("provider", string(t.name)).Time("open_until", t.openUntil).Msg("provider_circuit_open")
+	}
+}
+
+// abort ends a call that never reached a verdict. A half-open breaker goes
+// back to open with its cooldown elapsed so the next call probes again.
+func (t *Transport) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == BreakerHalfOpen {
+		t.state, t.openUntil = BreakerOpen, time.Now()
+	}
+}
+
+// doHTTP sends req through t, or the default client when a provider was
+// built without a transport.
+func doHTTP(t *Transport, req *http.Request) (*http.Response, error) {
+	if t == nil {
+		return http.DefaultClient.Do(req)
+	}
+	return t.Do(req)
+}
@@ -0,0 +1,299 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testTransport has no rate limit worth mentioning and millisecond backoffs.
+func testTransport(cfg TransportConfig) *Transport {
+	cfg.RPS, cfg.Burst = 1000, 1000
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = time.Millisecond
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Millisecond
+	}
+	return NewTransport("TEST", cfg)
+}
+
+// statusServer answers with the next status of codes, then with the last
+// one, recording every request body.
+type statusServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	codes  []int
+	header http.Header
+	bodies []string
+}
+
+func newStatusServer(t *testing.T, codes ...int) *statusServer {
+	s := &statusServer{codes: codes, header: http.Header{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(b))
+		code := s.codes[0]
+		if len(s.codes) > 1 {
+			s.codes = s.codes[1:]
+		}
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		s.mu.Unlock()
+		w.WriteHeader(code)
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *statusServer) setCodes(codes ...int) {
+	s.mu.Lock()
+	s.codes = codes
+	s.mu.Unlock()
+}
+
+func (s *statusServer) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func post(t *testing.T, tr *Transport, ctx context.Context, url, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tr.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestTransportRetriesWithBodyReplay(t *testing.T) {
+	srv := newStatusServer(t, 503, 429, 502, 200)
+	tr := testTransport(TransportConfig{MaxRetries: 3})
+
+	resp, err := post(t, tr, context.Background(), srv.URL, `{"prompt":"q"}`)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Do = %v, %v", resp, err)
+	}
+	for i, b := range srv.bodies {
+		if b != `{"prompt":"q"}` {
+			t.Errorf("attempt %d sent body %q", i+1, b)
+		}
+	}
+	if st := tr.Stats(); len(srv.bodies) != 4 || st.Retries != 3 || st.Requests != 4 || st.Failures != 0 {
+		t.Errorf("%d attempts, stats %+v", len(srv.bodies), st)
+	}
+}
+
+func TestTransportGivesUp(t *testing.T) {
+	srv := newStatusServer(t, 500)
+	tr := testTransport(TransportConfig{MaxRetries: 2})
+	if _, err := post(t, tr, context.Background(), srv.URL, "x"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("Do error %v", err)
+	}
+	if st := tr.Stats(); srv.calls() != 3 || st.Failures != 1 || st.LastError == "" {
+		t.Errorf("%d attempts, stats %+v", srv.calls(), st)
+	}
+}
+
+func TestTransportNoRetryWithoutGetBody(t *testing.T) {
+	srv := newStatusServer(t, 503, 200)
+	tr := testTransport(TransportConfig{MaxRetries: 3})
+	req, _ := http.NewRequest("POST", srv.URL, io.NopCloser(strings.NewReader("once")))
+	if _, err := tr.Do(req); err == nil {
+		t.Fatal("a body that cannot be replayed was retried")
+	}
+	if srv.calls() != 1 {
+		t.Errorf("%d attempts", srv.calls())
+	}
+}
+
+func TestTransportNoRetryOnClientError(t *testing.T) {
+	srv := newStatusServer(t, 400, 200)
+	tr := testTransport(TransportConfig{MaxRetries: 3})
+	resp, err := post(t, tr, context.Background(), srv.URL, "x")
+	if err != nil || resp.StatusCode != 400 || srv.calls() != 1 {
+		t.Errorf("Do = %v, %v after %d attempts", resp, err, srv.calls())
+	}
+}
+
+func TestTransportHonoursRetryAfter(t *testing.T) {
+	srv := newStatusServer(t, 429, 200)
+	srv.header.Set("Retry-After", "1")
+	tr := testTransport(TransportConfig{MaxRetries: 1, MaxBackoff: 5 * time.Second})
+
+	t0 := time.Now()
+	if _, err := post(t, tr, context.Background(), srv.URL, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(t0); d < time.Second || d > 3*time.Second {
+		t.Errorf("retried after %v, want the 1s of Retry-After", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.in); got < tt.min || got > tt.max {
+			t.Errorf("retryAfter(%q) = %v, want %v..%v", tt.in, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestTransportBreaker(t *testing.T) {
+	srv := newStatusServer(t, 500)
+	tr := testTransport(TransportConfig{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		post(t, tr, ctx, srv.URL, "x")
+	}
+	if st := tr.Stats(); st.State != BreakerOpen || st.OpenUntil == nil {
+		t.Fatalf("after 2 failures: %+v", st)
+	}
+	if _, err := post(t, tr, ctx, srv.URL, "x"); !errors.Is(err, ErrCircuitOpen) || srv.calls() != 2 {
+		t.Fatalf("open breaker: %v after %d calls", err, srv.calls())
+	}
+
+	// a failed probe reopens it at once
+	time.Sleep(60 * time.Millisecond)
+	post(t, tr, ctx, srv.URL, "x")
+	if st := tr.Stats(); st.State != BreakerOpen || srv.calls() != 3 || st.Rejected != 1 {
+		t.Fatalf("after failed probe: %+v, %d calls", st, srv.calls())
+	}
+
+	// one probe at a time while half-open
+	time.Sleep(60 * time.Millisecond)
+	if !tr.allow() || tr.Stats().State != BreakerHalfOpen || tr.allow() {
+		t.Fatalf("half-open breaker let a second call through: %+v", tr.Stats())
+	}
+	tr.abort()
+
+	// a successful probe closes it
+	srv.setCodes(200)
+	if _, err := post(t, tr, ctx, srv.URL, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if st := tr.Stats(); st.State != BreakerClosed {
+		t.Errorf("after successful probe: %+v", st)
+	}
+}
+
+func TestTransportCancelledProbe(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(500)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	tr := testTransport(TransportConfig{BreakerThreshold: 1, BreakerCooldown: 20 * time.Millisecond})
+
+	post(t, tr, context.Background(), srv.URL, "x")
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := post(t, tr, ctx, srv.URL, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cancelled probe: %v", err)
+	}
+	st := tr.Stats()
+	if st.State != BreakerOpen || st.Failures != 1 {
+		t.Fatalf("after cancelled probe: %+v", st)
+	}
+	// the cooldown is over, so the next call probes right away
+	if !tr.allow() {
+		t.Error("next call rejected after a cancelled probe")
+	}
+}
+
+func TestTransportTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	tr := testTransport(TransportConfig{Timeout: 50 * time.Millisecond, MaxRetries: 1})
+
+	t0 := time.Now()
+	_, err := post(t, tr, context.Background(), slow.URL, "x")
+	if err == nil || !strings.Contains(err.Error(), "no response within") {
+		t.Fatalf("Do error %v", err)
+	}
+	if d := time.Since(t0); d > 500*time.Millisecond {
+		t.Errorf("two attempts took %v", d)
+	}
+	if st := tr.Stats(); st.Requests != 2 || st.Failures != 1 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+// trickle writes n events, one every gap.
+func trickle(n int, gap time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		w.(http.Flusher).Flush()
+		for i := 0; i < n; i++ {
+			time.Sleep(gap)
+			io.WriteString(w, "data: x\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func TestTransportStreamOutlivesTimeout(t *testing.T) {
+	srv := httptest.NewServer(trickle(6, 30*time.Millisecond))
+	defer srv.Close()
+	tr := testTransport(TransportConfig{Timeout: 50 * time.Millisecond})
+
+	read := func(accept string) (int, error) {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := tr.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return strings.Count(string(b), "data:"), err
+	}
+
+	if n, err := read("text/event-stream"); err != nil || n != 6 {
+		t.Errorf("stream: %d events, %v", n, err)
+	}
+	// any other body must still arrive within the timeout
+	if n, err := read("application/json"); err == nil {
+		t.Errorf("plain request read %d events past the timeout", n)
+	}
+}
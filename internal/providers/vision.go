@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+)
+
+// Image is a prepared quiz image (see img.PrepareForOCR).
+type Image struct {
+	Data []byte
+	MIME string
+}
+
+func (im Image) base64() string { return base64.StdEncoding.EncodeToString(im.Data) }
+
+func (im Image) dataURL() string { return "data:" + im.MIME + ";base64," + im.base64() }
+
+// ImageAsker is implemented by providers that send the images set with
+// WithImages along with the prompt, from Ask and Stream alike. Others only
+// ever see the prompt text.
+type ImageAsker interface {
+	AcceptsImages() bool
+}
+
+func (c *OpenAI) AcceptsImages() bool    { return true }
+func (c *Anthropic) AcceptsImages() bool { return true }
+func (c *Gemini) AcceptsImages() bool    { return true }
+
+type imagesKey struct{}
+
+// WithImages attaches the quiz images to the provider requests of ctx.
+func WithImages(ctx context.Context, images []Image) context.Context {
+	return context.WithValue(ctx, imagesKey{}, images)
+}
+
+// ImagesFrom returns the images set by WithImages, or nil.
+func ImagesFrom(ctx context.Context) []Image {
+	images, _ := ctx.Value(imagesKey{}).([]Image)
+	return images
+}
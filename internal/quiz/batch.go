@@ -0,0 +1,324 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/lang"
+	"github.com/emandor/lemme_service/internal/model"
+	"github.com/emandor/lemme_service/internal/quota"
+	"github.com/emandor/lemme_service/internal/segment"
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+const (
+	// pageMaxW keeps worksheet pages legible; quiz screenshots use resizedMaxW.
+	pageMaxW = 1600
+	// maxConcurrentBatchQuizzes bounds how many child quizzes ask the
+	// providers at once.
+	maxConcurrentBatchQuizzes = 2
+)
+
+var errBatchQuota = errors.New("quota exceeded")
+
+// CreateBatch accepts a PDF worksheet ("file") and splits it into one quiz
+// per question in the background. Poll GET /quizzes/batch/:id for progress.
+func (h *Handler) CreateBatch(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+
+	var u model.User
+	if err := h.db.Get(&u, `SELECT id, quiz_quota, quiz_used FROM users WHERE id=?`, userID); err != nil {
+		return c.Status(500).SendString("db error")
+	}
+	uq := quota.UserQuota{QuizQuota: u.QuizQuota, QuizUsed: u.QuizUsed}
+	if !uq.CanCreateQuiz() {
+		return c.Status(403).SendString("quota exceeded")
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).SendString("file required")
+	}
+	data, err := readFormFile(fh)
+	if err != nil {
+		return c.Status(500).SendString("save fail")
+	}
+
+	key := "batches/" + uuid.New().String() + ".pdf"
+	if err := h.blob.Put(c.Context(), key, data, "application/pdf"); err != nil {
+		return c.Status(500).SendString("save fail")
+	}
+
+	res, err := h.db.Exec(`INSERT INTO quiz_batches (user_id, source_key, filename) VALUES (?, ?, ?)`,
+		userID, key, truncate(fh.Filename, 255))
+	if err != nil {
+		_ = h.blob.Delete(c.Context(), key)
+		return c.Status(500).SendString("db fail")
+	}
+	batchID, _ := res.LastInsertId()
+
+	h.svc.ProcessBatchAsync(batchID, h.cfg.PDFMaxPages, h.cfg.PDFRenderDPI)
+	return c.Status(202).JSON(fiber.Map{"id": batchID, "status": "processing"})
+}
+
+type BatchQuiz struct {
+	ID     int64  `db:"id" json:"id"`
+	Number int    `db:"batch_position" json:"number"`
+	Title  string `db:"title" json:"title"`
+	Status string `db:"status" json:"status"`
+}
+
+type BatchProgress struct {
+	Total      int `json:"total"`
+	Processing int `json:"processing"`
+	Completed  int `json:"completed"`
+	Error      int `json:"error"`
+}
+
+type BatchStatus struct {
+	ID            int64         `db:"id" json:"id"`
+	UserID        int64         `db:"user_id" json:"-"`
+	Filename      string        `db:"filename" json:"filename"`
+	Status        string        `db:"status" json:"status"`
+	Error         string        `db:"error_text" json:"error,omitempty"`
+	PageCount     int           `db:"page_count" json:"page_count"`
+	QuestionCount int           `db:"question_count" json:"question_count"`
+	Preamble      string        `db:"preamble" json:"preamble,omitempty"`
+	CreatedAt     string        `db:"created_at" json:"created_at"`
+	Progress      BatchProgress `json:"progress"`
+	Quizzes       []BatchQuiz   `json:"quizzes"`
+}
+
+// GetBatch reports a batch together with the aggregated progress of its
+// quizzes. The status is "processing" until every quiz has finished.
+func (h *Handler) GetBatch(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+
+	var b BatchStatus
+	if err := h.db.Get(&b, `
+        SELECT id,user_id,COALESCE(filename,'') AS filename,status,COALESCE(error_text,'') AS error_text,
+               COALESCE(page_count,0) AS page_count,COALESCE(question_count,0) AS question_count,
+               COALESCE(preamble,'') AS preamble,created_at
+        FROM quiz_batches WHERE id=?`, id); err != nil {
+		return c.Status(404).SendString("not found")
+	}
+	if b.UserID != userID {
+		return c.Status(403).SendString("forbidden")
+	}
+
+	b.Quizzes = []BatchQuiz{}
+	if err := h.db.Select(&b.Quizzes, `
+        SELECT id,batch_position,COALESCE(title,'') AS title,status
+        FROM quizzes WHERE batch_id=? AND deleted_at IS NULL
+        ORDER BY batch_position`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	for _, q := range b.Quizzes {
+		b.Progress.Total++
+		switch q.Status {
+		case "completed":
+			b.Progress.Completed++
+		case "error":
+			b.Progress.Error++
+		default:
+			b.Progress.Processing++
+		}
+	}
+	if b.Status == "ready" {
+		b.Status = "completed"
+		if b.Progress.Processing > 0 {
+			b.Status = "processing"
+		}
+	}
+	return c.JSON(b)
+}
+
+// ProcessBatchAsync renders the batch PDF, OCRs each page, splits the text
+// into questions and answers one child quiz per question.
+func (s *Service) ProcessBatchAsync(batchID int64, maxPages, dpi int) {
+	go func() {
+		log := telemetry.L().With().Int64("batch_id", batchID).Logger()
+		ctx := context.Background()
+
+		ids, err := s.splitBatch(ctx, batchID, maxPages, dpi)
+		if err != nil && len(ids) == 0 {
+			log.Error().Err(err).Msg("batch_fail")
+			_, _ = s.db.Exec(`UPDATE quiz_batches SET status='error', error_text=? WHERE id=?`, err.Error(), batchID)
+			return
+		}
+		if err != nil {
+			// quota ran out part way; keep the questions already created
+			log.Warn().Err(err).Int("quizzes", len(ids)).Msg("batch_partial")
+			_, _ = s.db.Exec(`UPDATE quiz_batches SET error_text=? WHERE id=?`, err.Error(), batchID)
+		}
+		_, _ = s.db.Exec(`UPDATE quiz_batches SET status='ready' WHERE id=?`, batchID)
+		log.Info().Int("quizzes", len(ids)).Msg("batch_split")
+
+		var g errgroup.Group
+		g.SetLimit(maxConcurrentBatchQuizzes)
+		for _, id := range ids {
+			g.Go(func() error {
+				// already one question each
+				s.answer(ctx, id, false)
+				return nil
+			})
+		}
+		_ = g.Wait()
+		log.Info().Msg("batch_completed")
+	}()
+}
+
+// splitBatch creates the child quizzes of a batch and returns their ids.
+func (s *Service) splitBatch(ctx context.Context, batchID int64, maxPages, dpi int) ([]int64, error) {
+	var b struct {
+		UserID    int64  `db:"user_id"`
+		SourceKey string `db:"source_key"`
+	}
+	if err := s.db.Get(&b, `SELECT user_id, source_key FROM quiz_batches WHERE id=?`, batchID); err != nil {
+		return nil, err
+	}
+	data, err := s.blob.Get(ctx, b.SourceKey)
+	if err != nil {
+		return nil, err
+	}
+	pngs, err := img.RenderPDF(ctx, data, maxPages, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]img.SaveResult, len(pngs))
+	texts := make([]string, len(pngs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentOCR)
+	for i, png := range pngs {
+		g.Go(func() error {
+			save, err := img.SaveResizedJPEG(gctx, s.blob, "quizzes/"+uuid.New().String()+".jpg", png, pageMaxW, img.Transform{})
+			if err != nil {
+				return err
+			}
+			pages[i] = save
+			res, err := s.ocrBlob(gctx, save.Key, save.Hash, OCRPlain)
+			texts[i] = res.Text
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		for _, p := range pages {
+			if p.Key != "" {
+				_ = s.blob.Delete(ctx, p.Key)
+			}
+		}
+		return nil, err
+	}
+
+	// join the pages and remember where each starts to map questions back
+	var sb strings.Builder
+	starts := make([]int, len(texts))
+	for i, t := range texts {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		starts[i] = sb.Len()
+		sb.WriteString(t)
+	}
+	preamble, questions := segment.Split(sb.String())
+	_, _ = s.db.Exec(`UPDATE quiz_batches SET page_count=?, question_count=?, preamble=? WHERE id=?`,
+		len(pages), len(questions), nullIfEmpty(preamble), batchID)
+	if len(questions) == 0 {
+		for _, p := range pages {
+			_ = s.blob.Delete(ctx, p.Key)
+		}
+		return nil, errors.New("no questions found")
+	}
+
+	// drop page images no question ended up on
+	used := make([]bool, len(pages))
+	defer func() {
+		for i, p := range pages {
+			if !used[i] {
+				_ = s.blob.Delete(ctx, p.Key)
+			}
+		}
+	}()
+
+	var ids []int64
+	for _, q := range questions {
+		page := 0
+		for i, st := range starts {
+			if st <= q.Offset {
+				page = i
+			}
+		}
+		id, err := s.createBatchQuiz(b.UserID, batchID, q, pages[page])
+		if err != nil {
+			return ids, err
+		}
+		used[page] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// createBatchQuiz charges the user and inserts a quiz for one question,
+// showing the page it starts on.
+func (s *Service) createBatchQuiz(userID, batchID int64, q segment.Question, page img.SaveResult) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET quiz_used=quiz_used+? WHERE id=? AND quiz_used+? <= quiz_quota`,
+		quota.Cost(false), userID, quota.Cost(false))
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, errBatchQuota
+	}
+
+	// text only: the page image may hold other questions too
+	res, err = tx.Exec(`
+        INSERT INTO quizzes
+            (user_id, batch_id, batch_position, title, image_key, image_hash, image_phash, image_width, image_height,
+             ocr_text, ocr_lang, input_mode, status, created_at, updated_at)
+        VALUES (?, ?, ?, NULL, ?, ?, ?, ?, ?, ?, ?, ?, 'processing', NOW(), NOW())`,
+		userID, batchID, q.Number, page.Key, page.Hash, page.PHash, page.Width, page.Height, q.Text,
+		nullIfEmpty(lang.Detect(q.Text, lang.Candidates(s.ocrLang)...)), InputText)
+	if err != nil {
+		return 0, err
+	}
+	qid, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+        INSERT INTO quiz_images (quiz_id, position, image_key, image_hash, image_width, image_height, ocr_text, ocr_status)
+        VALUES (?, 0, ?, ?, ?, ?, ?, 'done')`,
+		qid, page.Key, page.Hash, page.Width, page.Height, q.Text); err != nil {
+		return 0, err
+	}
+	return qid, tx.Commit()
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,235 @@
+package quiz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/model"
+	"github.com/emandor/lemme_service/internal/quota"
+	"github.com/emandor/lemme_service/internal/telemetry"
+	"github.com/emandor/lemme_service/internal/ws"
+)
+
+// resizedMaxW is the width of the stored quiz image.
+const resizedMaxW = 700
+
+// cropRequest is the client crop in pixels of the (EXIF-oriented) original,
+// after a clockwise rotation in degrees.
+type cropRequest struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	W      int     `json:"w"`
+	H      int     `json:"h"`
+	Rotate float64 `json:"rotate"`
+}
+
+func (r cropRequest) validate() error {
+	if r.X < 0 || r.Y < 0 || r.W < 0 || r.H < 0 {
+		return errors.New("invalid crop")
+	}
+	if (r.W == 0) != (r.H == 0) {
+		return errors.New("crop needs both w and h")
+	}
+	if r.Rotate < -360 || r.Rotate > 360 {
+		return errors.New("invalid rotate")
+	}
+	return nil
+}
+
+func (r cropRequest) transform() img.Transform {
+	t := img.Transform{Rotate: r.Rotate}
+	if r.W > 0 && r.H > 0 {
+		t.Crop = image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+	}
+	return t
+}
+
+// parseCropForm reads the optional crop_x, crop_y, crop_w, crop_h and rotate
+// multipart fields. It returns nil when none is set.
+func parseCropForm(c *fiber.Ctx) (*cropRequest, error) {
+	var r cropRequest
+	set := false
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"crop_x", &r.X}, {"crop_y", &r.Y}, {"crop_w", &r.W}, {"crop_h", &r.H}} {
+		if v := c.FormValue(f.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.New("invalid " + f.name)
+			}
+			*f.dst, set = n, true
+		}
+	}
+	if v := c.FormValue("rotate"); v != "" {
+		a, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("invalid rotate")
+		}
+		r.Rotate, set = a, true
+	}
+	if !set {
+		return nil, nil
+	}
+	return &r, r.validate()
+}
+
+// storedImage is an upload saved as the resized quiz image plus the
+// untouched original, which later re-crops start from.
+type storedImage struct {
+	img.SaveResult
+	OriginalKey string
+	Crop        *cropRequest
+}
+
+func (s storedImage) cropJSON() any {
+	if s.Crop == nil {
+		return nil
+	}
+	b, _ := json.Marshal(s.Crop)
+	return string(b)
+}
+
+// storeUpload keeps the original bytes and the cropped, resized JPEG.
+func (h *Handler) storeUpload(ctx context.Context, fh *multipart.FileHeader, src []byte, crop *cropRequest) (storedImage, error) {
+	uid := uuid.New().String()
+	var t img.Transform
+	if crop != nil {
+		t = crop.transform()
+	}
+	save, err := img.SaveResizedJPEG(ctx, h.blob, "quizzes/"+uid+".jpg", src, resizedMaxW, t)
+	if err != nil {
+		return storedImage{}, err
+	}
+
+	orig := "quizzes/originals/" + uid + strings.ToLower(filepath.Ext(fh.Filename))
+	if err := h.blob.Put(ctx, orig, src, fh.Header.Get("Content-Type")); err != nil {
+		_ = h.blob.Delete(ctx, save.Key)
+		return storedImage{}, err
+	}
+	return storedImage{SaveResult: save, OriginalKey: orig, Crop: crop}, nil
+}
+
+// CropQuiz re-crops the stored original of one quiz image and runs OCR and
+// the providers again. Body: {"x","y","w","h","rotate","position"}, where
+// position picks the image (default 0).
+func (h *Handler) CropQuiz(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	log := telemetry.L().With().Int64("user_id", userID).Int64("quiz_id", id).Logger()
+
+	var body struct {
+		cropRequest
+		Position int `json:"position"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+	if err := body.validate(); err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	var status string
+	if err := h.db.Get(&status, `SELECT status FROM quizzes WHERE id=?`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	if status == "processing" {
+		return c.Status(409).SendString("quiz is processing")
+	}
+	var q struct {
+		ImageKey    string  `db:"image_key"`
+		OriginalKey *string `db:"original_key"`
+	}
+	if err := h.db.Get(&q, `SELECT image_key,original_key FROM quiz_images WHERE quiz_id=? AND position=?`, id, body.Position); err != nil {
+		return c.Status(404).SendString("image not found")
+	}
+	if q.OriginalKey == nil {
+		return c.Status(409).SendString("original image not available")
+	}
+
+	var u model.User
+	if err := h.db.Get(&u, `SELECT id, quiz_quota, quiz_used FROM users WHERE id=?`, userID); err != nil {
+		return c.Status(500).SendString("db error")
+	}
+	uq := quota.UserQuota{QuizQuota: u.QuizQuota, QuizUsed: u.QuizUsed}
+	if !uq.CanCreateQuiz() {
+		return c.Status(403).SendString("quota exceeded")
+	}
+
+	src, err := h.blob.Get(c.Context(), *q.OriginalKey)
+	if err != nil {
+		log.Error().Err(err).Msg("original_load_fail")
+		return c.Status(500).SendString("load fail")
+	}
+	save, err := img.SaveResizedJPEG(c.Context(), h.blob, "quizzes/"+uuid.New().String()+".jpg", src, resizedMaxW, body.transform())
+	if errors.Is(err, img.ErrCropOutside) {
+		return c.Status(400).SendString(err.Error())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("resize_or_store_fail")
+		return c.Status(500).SendString("resize fail")
+	}
+	im := storedImage{SaveResult: save, OriginalKey: *q.OriginalKey, Crop: &body.cropRequest}
+
+	if err := h.svc.ResetForReprocess(id, body.Position, im); err != nil {
+		_ = h.blob.Delete(c.Context(), save.Key)
+		return c.Status(500).SendString("db fail")
+	}
+	_ = h.blob.Delete(c.Context(), q.ImageKey)
+	log.Info().Msg("quiz_recropped")
+
+	var cover string
+	_ = h.db.Get(&cover, `SELECT image_key FROM quizzes WHERE id=?`, id)
+	imageURL := h.imageURL(c.Context(), cover)
+	ws.BroadcastNewQuiz(userID, id, imageURL)
+	h.svc.ProcessAsync(id, save.Key)
+	_, _ = h.db.Exec(`UPDATE users SET quiz_used=quiz_used+? WHERE id=?`, quota.Cost(false), userID)
+	return c.JSON(fiber.Map{"id": id, "status": "processing", "image_path": imageURL})
+}
+
+// ResetForReprocess points one quiz image (and the cover, for position 0)
+// at a new file and drops the OCR text and answers derived from the old one.
+func (s *Service) ResetForReprocess(quizID int64, position int, im storedImage) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+        UPDATE quiz_images
+        SET image_key=?, image_hash=?, image_width=?, image_height=?, ocr_text=NULL, ocr_latex=NULL, ocr_status='pending', ocr_error=NULL
+        WHERE quiz_id=? AND position=?`,
+		im.Key, im.Hash, im.Width, im.Height, quizID, position); err != nil {
+		return err
+	}
+	if position == 0 {
+		if _, err := tx.Exec(`
+            UPDATE quizzes
+            SET image_key=?, image_hash=?, image_phash=?, image_width=?, image_height=?, crop_json=?
+            WHERE id=?`,
+			im.Key, im.Hash, im.PHash, im.Width, im.Height, im.cropJSON(), quizID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE quizzes SET ocr_text=NULL, ocr_latex=NULL, status='processing', updated_at=NOW() WHERE id=?`, quizID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM answers WHERE quiz_id=?`, quizID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
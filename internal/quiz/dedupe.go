@@ -0,0 +1,135 @@
+package quiz
+
+import (
+	"cmp"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/img"
+)
+
+const (
+	// maxHashCandidates bounds how many earlier quizzes are compared by
+	// perceptual hash; Hamming distance cannot use an index.
+	maxHashCandidates = 500
+	maxSimilarQuizzes = 5
+
+	// singleImageQuiz limits hash matching to quizzes q of one screenshot
+	// of their own: multi-image quizzes only hash their cover and batch
+	// quizzes share the page image of their siblings.
+	singleImageQuiz = `q.batch_id IS NULL AND (SELECT COUNT(*) FROM quiz_images i WHERE i.quiz_id=q.id)=1`
+)
+
+// FindDuplicate returns the newest completed single-image quiz of the user
+// inside window that has at least one usable answer, was read and asked in
+// the same input and OCR modes (empty meaning the default) and has either
+// the same SHA-256 or, when maxDist >= 0, a perceptual hash at most maxDist
+// bits away.
+func (s *Service) FindDuplicate(userID int64, save img.SaveResult, mode InputMode, ocrMode OCRMode, window time.Duration, maxDist int) (int64, bool) {
+	const answered = `q.user_id=? AND q.status='completed' AND q.deleted_at IS NULL
+            AND q.created_at > NOW() - INTERVAL ? SECOND
+            AND COALESCE(q.input_mode, ?)=? AND COALESCE(q.ocr_mode, ?)=?
+            AND EXISTS (SELECT 1 FROM answers a WHERE a.quiz_id=q.id AND a.answer_text<>'ERROR')
+            AND ` + singleImageQuiz
+	secs := int64(window / time.Second)
+	// a quiz stored without a mode followed the default
+	mode, ocrMode = cmp.Or(mode, s.inputMode), cmp.Or(ocrMode, s.ocrMode)
+	args := []any{userID, secs, s.inputMode, mode, s.ocrMode, ocrMode}
+
+	var id int64
+	if err := s.db.Get(&id, `
+        SELECT q.id FROM quizzes q
+        WHERE q.image_hash=? AND `+answered+`
+        ORDER BY q.id DESC LIMIT 1`, append([]any{save.Hash}, args...)...); err == nil {
+		return id, true
+	}
+	if maxDist < 0 {
+		return 0, false
+	}
+
+	cands, err := s.loadHashes(`
+        SELECT q.id, q.image_phash FROM quizzes q
+        WHERE q.image_phash IS NOT NULL AND `+answered+`
+        ORDER BY q.id DESC LIMIT ?`, append(args, maxHashCandidates)...)
+	if err != nil {
+		return 0, false
+	}
+	if m := img.NearestHashes(save.PHash, cands, maxDist); len(m) > 0 {
+		return m[0].ID, true
+	}
+	return 0, false
+}
+
+// SimilarQuizzes lists the user's other single-image quizzes whose image
+// looks like the one of quizID, closest first.
+func (s *Service) SimilarQuizzes(userID, quizID int64, phash uint64, maxDist, limit int) ([]img.HashMatch, error) {
+	cands, err := s.loadHashes(`
+        SELECT q.id, q.image_phash FROM quizzes q
+        WHERE q.user_id=? AND q.id<>? AND q.image_phash IS NOT NULL AND q.deleted_at IS NULL
+            AND `+singleImageQuiz+`
+        ORDER BY q.id DESC LIMIT ?`, userID, quizID, maxHashCandidates)
+	if err != nil {
+		return nil, err
+	}
+	m := img.NearestHashes(phash, cands, maxDist)
+	if len(m) > limit {
+		m = m[:limit]
+	}
+	return m, nil
+}
+
+func (s *Service) loadHashes(query string, args ...any) ([]img.HashedImage, error) {
+	var rows []struct {
+		ID    int64  `db:"id"`
+		PHash uint64 `db:"image_phash"`
+	}
+	if err := s.db.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+	out := make([]img.HashedImage, len(rows))
+	for i, r := range rows {
+		out[i] = img.HashedImage{ID: r.ID, Hash: r.PHash}
+	}
+	return out, nil
+}
+
+// CloneQuiz creates a completed quiz for the freshly stored image that
+// reuses the OCR text and the successful answers of srcID.
+func (s *Service) CloneQuiz(srcID int64, im storedImage) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        INSERT INTO quizzes
+            (user_id, source_quiz_id, title, image_key, original_key, crop_json, image_hash, image_phash,
+             image_width, image_height, ocr_text, ocr_latex, ocr_mode, ocr_lang, subject, input_mode, status, created_at, updated_at)
+        SELECT user_id, id, title, ?, ?, ?, ?, ?, ?, ?, ocr_text, ocr_latex, ocr_mode, ocr_lang, subject, input_mode, 'completed', NOW(), NOW()
+        FROM quizzes WHERE id=?`,
+		im.Key, im.OriginalKey, im.cropJSON(), im.Hash, im.PHash, im.Width, im.Height, srcID)
+	if err != nil {
+		return 0, err
+	}
+	qid, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO quiz_images (quiz_id, position, image_key, original_key, image_hash, image_width, image_height, ocr_text, ocr_latex, ocr_status)
+        SELECT ?, 0, ?, ?, ?, ?, ?, ocr_text, ocr_latex, 'done' FROM quizzes WHERE id=?`,
+		qid, im.Key, im.OriginalKey, im.Hash, im.Width, im.Height, srcID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO answers (quiz_id, source, question_no, answer_text, reason_text, score, latency_ms, token_usage_json,
+             prompt_version, parse_mode, parse_confidence, parse_warnings_json, raw_text, reasoning_text, input_mode, created_at)
+        SELECT ?, source, question_no, answer_text, reason_text, score, latency_ms, token_usage_json,
+               prompt_version, parse_mode, parse_confidence, parse_warnings_json, raw_text, reasoning_text, input_mode, NOW()
+        FROM answers WHERE quiz_id=? AND answer_text<>'ERROR'`, qid, srcID); err != nil {
+		return 0, err
+	}
+	return qid, tx.Commit()
+}
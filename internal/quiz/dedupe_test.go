@@ -0,0 +1,106 @@
+package quiz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/img"
+)
+
+func TestFindDuplicateSingleImageOnly(t *testing.T) {
+	s := testService(t)
+	user := seedUser(t, s.db)
+
+	single := seedQuiz(t, s.db, user, "single", 1)
+	multi := seedQuiz(t, s.db, user, "multi", 3)
+	child := seedQuiz(t, s.db, user, "child", 1)
+	res := s.db.MustExec(`INSERT INTO quiz_batches (user_id, source_key, status) VALUES (?, 'batches/1.pdf', 'ready')`, user)
+	batchID, _ := res.LastInsertId()
+	s.db.MustExec(`UPDATE quizzes SET batch_id=?, batch_position=0 WHERE id=?`, batchID, child)
+	for _, id := range []int64{single, multi, child} {
+		seedAnswer(t, s.db, id, "OPENAI", "B")
+	}
+	s.db.MustExec(`UPDATE quizzes SET image_phash=? WHERE id IN (?, ?, ?)`, uint64(0xF0F0), single, multi, child)
+
+	tests := []struct {
+		name    string
+		save    img.SaveResult
+		maxDist int
+		want    int64
+	}{
+		{"same hash", img.SaveResult{Hash: "single"}, -1, single},
+		{"multi-image hash", img.SaveResult{Hash: "multi"}, -1, 0},
+		{"batch child hash", img.SaveResult{Hash: "child"}, -1, 0},
+		{"near phash", img.SaveResult{Hash: "new", PHash: 0xF0F1}, 4, single},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.FindDuplicate(user, tt.save, "", "", time.Hour, tt.maxDist)
+			if got != tt.want || ok != (tt.want != 0) {
+				t.Errorf("FindDuplicate = %d, %v; want %d", got, ok, tt.want)
+			}
+		})
+	}
+
+	s.db.MustExec(`UPDATE quizzes SET image_phash=NULL WHERE id=?`, single)
+	if got, ok := s.FindDuplicate(user, img.SaveResult{Hash: "new", PHash: 0xF0F0}, "", "", time.Hour, 4); ok {
+		t.Errorf("phash matched %d among multi-image and batch quizzes", got)
+	}
+}
+
+func TestSimilarQuizzesSingleImageOnly(t *testing.T) {
+	s := testService(t)
+	user := seedUser(t, s.db)
+
+	self := seedQuiz(t, s.db, user, "self", 1)
+	single := seedQuiz(t, s.db, user, "single", 1)
+	multi := seedQuiz(t, s.db, user, "multi", 2)
+	child := seedQuiz(t, s.db, user, "child", 1)
+	res := s.db.MustExec(`INSERT INTO quiz_batches (user_id, source_key, status) VALUES (?, 'batches/1.pdf', 'ready')`, user)
+	batchID, _ := res.LastInsertId()
+	s.db.MustExec(`UPDATE quizzes SET batch_id=?, batch_position=0 WHERE id=?`, batchID, child)
+	s.db.MustExec(`UPDATE quizzes SET image_phash=? WHERE id IN (?, ?, ?, ?)`, uint64(0xABCD), self, single, multi, child)
+
+	got, err := s.SimilarQuizzes(user, self, 0xABCD, 4, maxSimilarQuizzes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != single {
+		t.Errorf("SimilarQuizzes = %+v, want only quiz %d", got, single)
+	}
+}
+
+func TestFindDuplicateSameModes(t *testing.T) {
+	s := testService(t)
+	s.inputMode, s.ocrMode = InputText, OCRPlain
+	user := seedUser(t, s.db)
+
+	// stored without modes, so read as text with plain OCR by default
+	plain := seedQuiz(t, s.db, user, "shot", 1)
+	math := seedQuiz(t, s.db, user, "shot", 1)
+	s.db.MustExec(`UPDATE quizzes SET ocr_mode='math' WHERE id=?`, math)
+	for _, id := range []int64{plain, math} {
+		seedAnswer(t, s.db, id, "OPENAI", "B")
+	}
+
+	tests := []struct {
+		name    string
+		mode    InputMode
+		ocrMode OCRMode
+		want    int64
+	}{
+		{"defaults", "", "", plain},
+		{"explicit defaults", InputText, OCRPlain, plain},
+		{"math", "", OCRMath, math},
+		{"vision", InputVision, "", 0},
+		{"hybrid math", InputHybrid, OCRMath, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.FindDuplicate(user, img.SaveResult{Hash: "shot"}, tt.mode, tt.ocrMode, time.Hour, -1)
+			if got != tt.want || ok != (tt.want != 0) {
+				t.Errorf("FindDuplicate = %d, %v; want %d", got, ok, tt.want)
+			}
+		})
+	}
+}
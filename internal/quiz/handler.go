@@ -1,12 +1,15 @@
 package quiz
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
 
@@ -17,36 +20,55 @@ import (
 	"github.com/emandor/lemme_service/internal/ocr"
 	"github.com/emandor/lemme_service/internal/providers"
 	"github.com/emandor/lemme_service/internal/quota"
+	"github.com/emandor/lemme_service/internal/storage"
 	"github.com/emandor/lemme_service/internal/telemetry"
 	"github.com/emandor/lemme_service/internal/ws"
 )
 
 type Handler struct {
-	cfg *config.Config
-	db  *sqlx.DB
-	rdb *redis.Client
-	svc *Service
+	cfg  *config.Config
+	db   *sqlx.DB
+	rdb  *redis.Client
+	svc  *Service
+	blob storage.Blob
 }
 
 func buildProviders(cfg *config.Config) []providers.Client {
 	var list []providers.Client
 	// set to DRY_RUN mode for testing without API calls
 	dryRun := false
+	tc := providers.TransportConfig{
+		Timeout:          cfg.ProviderTimeout,
+		RPS:              float64(cfg.ProviderRPS),
+		Burst:            cfg.ProviderBurst,
+		MaxRetries:       cfg.ProviderMaxRetries,
+		BreakerThreshold: cfg.ProviderBreakerThreshold,
+		BreakerCooldown:  cfg.ProviderBreakerCooldown,
+	}
 	if cfg.OpenAIKey != "" {
-		list = append(list, &providers.OpenAI{Key: cfg.OpenAIKey, Model: cfg.OpenAIModel, DryRun: dryRun})
+		list = append(list, &providers.OpenAI{Key: cfg.OpenAIKey, Model: cfg.OpenAIModel, DryRun: dryRun,
+			HTTP: providers.NewTransport(providers.SourceOpenAI, tc), Structured: cfg.ProviderStructured,
+			Gen: providers.Generation{MaxTokens: cfg.OpenAIMaxTokens, Temperature: cfg.OpenAITemperature, ReasoningEffort: cfg.OpenAIReasoningEffort}})
 	}
 	if cfg.AnthropicKey != "" {
-		list = append(list, &providers.Anthropic{Key: cfg.AnthropicKey, Model: cfg.AnthropicModel, DryRun: dryRun})
+		list = append(list, &providers.Anthropic{Key: cfg.AnthropicKey, Model: cfg.AnthropicModel, DryRun: dryRun,
+			HTTP: providers.NewTransport(providers.SourceClaude, tc), Structured: cfg.ProviderStructured,
+			Gen: providers.Generation{MaxTokens: cfg.AnthropicMaxTokens, Temperature: cfg.AnthropicTemperature, ThinkingBudget: cfg.AnthropicThinkingBudget}})
 	}
 	if cfg.GeminiKey != "" {
-		list = append(list, &providers.Gemini{Key: cfg.GeminiKey, Model: cfg.GeminiModel, DryRun: dryRun})
+		list = append(list, &providers.Gemini{Key: cfg.GeminiKey, Model: cfg.GeminiModel, DryRun: dryRun,
+			HTTP: providers.NewTransport(providers.SourceGemini, tc), Structured: cfg.ProviderStructured,
+			Gen: providers.Generation{MaxTokens: cfg.GeminiMaxTokens, Temperature: cfg.GeminiTemperature, ThinkingBudget: cfg.GeminiThinkingBudget}})
 	}
 	return list
 }
 
-func NewHandler(cfg *config.Config, db *sqlx.DB, rdb *redis.Client) *Handler {
+func NewHandler(cfg *config.Config, db *sqlx.DB, rdb *redis.Client, blob storage.Blob) *Handler {
 	clients := buildProviders(cfg) // init OpenAI/Anthropic/DeepSeek
-	svc := &Service{db: db, rdb: rdb, clients: clients, ocrLang: cfg.OCRLang}
+	svc := &Service{db: db, rdb: rdb, blob: blob, clients: clients, ocrLang: cfg.OCRLang}
+	svc.titleSource = providers.SourceName(strings.ToUpper(cfg.TitleProvider))
+	svc.titleModel, svc.titleMaxTokens = cfg.TitleModel, cfg.TitleMaxTokens
+	svc.stream = cfg.ProviderStream
 
 	vision := ocr.NewOpenAIVision(
 		cfg.OpenAIKey,
@@ -60,8 +82,49 @@ func NewHandler(cfg *config.Config, db *sqlx.DB, rdb *redis.Client) *Handler {
 	svc.ocrMaxW = cfg.OCRImgMaxW
 	svc.ocrQuality = cfg.OCRImgQuality
 	svc.ocrGray = cfg.OCRImgGrayscale
+
+	steps, err := img.ParseSteps(cfg.OCRPrepSteps)
+	if err != nil {
+		log := telemetry.L()
+		log.Fatal().Err(err).Msg("invalid OCR_PREP_STEPS")
+	}
+	svc.ocrSteps = steps
+
+	strategy, err := parseStrategy(cfg.ProviderStrategy)
+	if err != nil {
+		log := telemetry.L()
+		log.Fatal().Err(err).Msg("invalid PROVIDER_STRATEGY")
+	}
+	if strategy == "" {
+		strategy = StrategyAll
+	}
+	svc.strategy = strategy
+	mode, err := parseInputMode(cfg.QuizInputMode)
+	if err != nil {
+		log := telemetry.L()
+		log.Fatal().Err(err).Msg("invalid QUIZ_INPUT_MODE")
+	}
+	if mode == "" {
+		mode = InputText
+	}
+	svc.inputMode = mode
+	ocrMode, err := parseOCRMode(cfg.OCRMode)
+	if err != nil {
+		log := telemetry.L()
+		log.Fatal().Err(err).Msg("invalid OCR_MODE")
+	}
+	if ocrMode == "" {
+		ocrMode = OCRPlain
+	}
+	svc.ocrMode = ocrMode
+	svc.prompts = newPromptStore(db, cfg.PromptTemplateDir, cfg.PromptWeights)
+	svc.prompts.start(cfg.PromptReloadInterval)
+	svc.firstK = cfg.ProviderFirstK
+	svc.hedgeDelay = cfg.ProviderHedgeDelay
+	img.HEIFDecoderCmd = cfg.HEIFDecoderCmd
+	img.PDFRenderCmd = cfg.PDFRenderCmd
 	svc.ocrCacheTTL = cfg.OCRCacheTTL
-	return &Handler{cfg: cfg, db: db, rdb: rdb, svc: svc}
+	return &Handler{cfg: cfg, db: db, rdb: rdb, svc: svc, blob: blob}
 }
 
 func (h *Handler) CreateQuiz(c *fiber.Ctx) error {
@@ -76,8 +139,11 @@ func (h *Handler) CreateQuiz(c *fiber.Ctx) error {
 		return c.Status(500).SendString("db error")
 	}
 
+	// dedupe is opt-in; force=true always runs the providers again
+	dedupe := c.FormValue("dedupe", strconv.FormatBool(h.cfg.QuizDedupe)) == "true" && c.FormValue("force") != "true"
+
 	uq := quota.UserQuota{QuizQuota: u.QuizQuota, QuizUsed: u.QuizUsed}
-	if !uq.CanCreateQuiz() {
+	if !dedupe && !uq.CanCreateQuiz() {
 		return c.Status(403).SendString("quota exceeded")
 	}
 
@@ -85,97 +151,247 @@ func (h *Handler) CreateQuiz(c *fiber.Ctx) error {
 		return c.Status(500).SendString("db error")
 	}
 
-	fh, err := c.FormFile("image")
+	files, err := uploadFiles(c, h.cfg.QuizMaxImages)
 	if err != nil {
-		return c.Status(400).SendString("image required")
+		return c.Status(400).SendString(err.Error())
 	}
 
-	uid := uuid.New().String()
-	tmp := filepath.Join(os.TempDir(), uid)
-	if err := c.SaveFile(fh, tmp); err != nil {
-		return c.Status(500).SendString("save fail")
+	crop, err := parseCropForm(c)
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	if crop != nil && len(files) > 1 {
+		return c.Status(400).SendString("crop needs a single image")
 	}
 
-	save, err := img.SaveResizedJPEG(tmp, "./storage/quizzes", 700)
+	// empty keeps following PROVIDER_STRATEGY
+	strategy, err := parseStrategy(c.FormValue("strategy"))
 	if err != nil {
-		return c.Status(500).SendString("resize fail")
+		return c.Status(400).SendString(err.Error())
 	}
+	// empty keeps following QUIZ_INPUT_MODE
+	mode, err := parseInputMode(c.FormValue("input_mode"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	// empty keeps following OCR_MODE
+	ocrMode, err := parseOCRMode(c.FormValue("ocr_mode"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	// optional hint for prompt templates, e.g. "math"
+	subject := truncate(strings.TrimSpace(c.FormValue("subject")), maxSubjectLen)
+
+	images := make([]storedImage, 0, len(files))
+	for _, fh := range files {
+		src, err := readFormFile(fh)
+		if err != nil {
+			h.deleteStored(c.Context(), images)
+			return c.Status(500).SendString("save fail")
+		}
+
+		stored, err := h.storeUpload(c.Context(), fh, src, crop)
+		if err != nil {
+			h.deleteStored(c.Context(), images)
+		}
+		switch {
+		case errors.Is(err, img.ErrCropOutside):
+			return c.Status(400).SendString(err.Error())
+		case errors.Is(err, img.ErrUnsupportedFormat):
+			return c.Status(415).SendString("unsupported image format")
+		case errors.Is(err, img.ErrCorrupt):
+			log.Warn().Err(err).Str("file", fh.Filename).Msg("image_decode_fail")
+			return c.Status(400).SendString("invalid image")
+		case err != nil:
+			log.Error().Err(err).Msg("resize_or_store_fail")
+			return c.Status(500).SendString("resize fail")
+		}
+		images = append(images, stored)
+	}
+	save := images[0].SaveResult
+
+	// only single-screenshot quizzes are deduplicated
+	if dedupe && len(images) == 1 {
+		if srcID, ok := h.svc.FindDuplicate(userID, save, mode, ocrMode, h.cfg.QuizDedupeWindow, h.cfg.QuizDedupePHashDist); ok {
+			qid, err := h.svc.CloneQuiz(srcID, images[0])
+			if err != nil {
+				log.Error().Err(err).Int64("source_quiz_id", srcID).Msg("dedupe_clone_fail")
+				return c.Status(500).SendString("db fail")
+			}
+			log.Info().Int64("quiz_id", qid).Int64("source_quiz_id", srcID).Msg("quiz_deduplicated")
 
-	var id int64
-	res, err := h.db.Exec(`
-  INSERT INTO quizzes
-    (user_id, title, image_path, image_hash, image_width, image_height, status, created_at, updated_at)
-  VALUES
-    (?, NULL, ?, ?, ?, ?, 'processing', NOW(), NOW())
-`, userID, save.Path, save.Hash, save.Width, save.Height)
+			imageURL := h.imageURL(c.Context(), save.Key)
+			ws.BroadcastNewQuiz(userID, qid, imageURL)
+			if cost := quota.Cost(true); cost > 0 {
+				_, _ = h.db.Exec(`UPDATE users SET quiz_used=quiz_used+? WHERE id=?`, cost, userID)
+			}
+			return c.JSON(fiber.Map{"id": qid, "status": "completed", "image_path": imageURL, "deduplicated_from": srcID})
+		}
+	}
+	if dedupe && !uq.CanCreateQuiz() {
+		h.deleteStored(c.Context(), images)
+		return c.Status(403).SendString("quota exceeded")
+	}
+
+	qid, err := h.svc.CreateQuiz(userID, images, strategy, mode, ocrMode, subject)
 	if err != nil {
+		h.deleteStored(c.Context(), images)
 		return c.Status(500).SendString("db fail")
 	}
 
-	qid, _ := res.LastInsertId()
-	log.Info().Int64("quiz_id", id).Msg("quiz_created")
+	log.Info().Int64("quiz_id", qid).Int("images", len(images)).Msg("quiz_created")
 	// need to broadcast new quiz to user via websocket
-	ws.BroadcastNewQuiz(userID, qid, save.Path)
+	imageURL := h.imageURL(c.Context(), save.Key)
+	ws.BroadcastNewQuiz(userID, qid, imageURL)
 
 	// Async process
-	h.svc.ProcessAsync(qid, save.Path)
-	_, _ = h.db.Exec(`UPDATE users SET quiz_used=quiz_used+1 WHERE id=?`, userID)
-	return c.JSON(fiber.Map{"id": qid, "status": "processing", "image_path": save.Path})
+	h.svc.ProcessAsync(qid, save.Key)
+	_, _ = h.db.Exec(`UPDATE users SET quiz_used=quiz_used+? WHERE id=?`, quota.Cost(false), userID)
+	return c.JSON(fiber.Map{"id": qid, "status": "processing", "image_path": imageURL, "images": len(images)})
 }
 
 type QuizRow struct {
 	ID        int64       `db:"id" json:"id"`
+	Title     string      `db:"title" json:"title"`
 	Status    string      `db:"status" json:"status"`
 	OcrText   string      `db:"ocr_text" json:"ocr_text"`
-	ImagePath string      `db:"image_path" json:"image_path"`
+	ImagePath string      `db:"image_key" json:"image_path"`
 	CreatedAt string      `db:"created_at" json:"created_at"`
+	Tags      []string    `json:"tags"`
 	Answers   []AnswerRow `json:"answers"`
 }
 
 type AnswerRow struct {
-	Source    string `db:"source" json:"source"`
-	Answer    string `db:"answer_text" json:"answer_text"`
-	Reason    string `db:"reason_text" json:"reason_text"`
+	QuizID     int64  `db:"quiz_id" json:"-"`
+	Source     string `db:"source" json:"source"`
+	QuestionNo int    `db:"question_no" json:"question_no"`
+	Answer     string `db:"answer_text" json:"answer_text"`
+	Reason     string `db:"reason_text" json:"reason_text"`
+	// PromptVersion is the prompt template that produced the answer.
+	PromptVersion string `db:"prompt_version" json:"prompt_version,omitempty"`
+	// ParseMode is how the answer was read from the reply, e.g.
+	// "structured" or a heuristic fallback such as "keyword".
+	ParseMode       string  `db:"parse_mode" json:"parse_mode,omitempty"`
+	ParseConfidence float64 `db:"parse_confidence" json:"parse_confidence"`
+	// InputMode is what the provider was shown: text, vision or hybrid.
+	InputMode string `db:"input_mode" json:"input_mode,omitempty"`
 	CreatedAt string `db:"created_at" json:"created_at"`
 }
 
+type ListMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListMyQuizzes returns the caller's quizzes newest first, one page at a time.
+// Query: limit, cursor (from meta.next_cursor), status, provider, from, to,
+// tag (name) and folder (id).
 func (h *Handler) ListMyQuizzes(c *fiber.Ctx) error {
 	userID := mustUserID(c)
 
+	f, err := parseListFilter(c)
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	where, args := f.where(userID)
+	// fetch one extra row to know whether there is a next page
+	args = append(args, f.Limit+1)
+
 	var quizzes []QuizRow
 	if err := h.db.Select(&quizzes, `
-        SELECT id,status,ocr_text,image_path,created_at
-        FROM quizzes
-        WHERE user_id=? ORDER BY id DESC`, userID); err != nil {
+        SELECT q.id,COALESCE(q.title,'') AS title,q.status,COALESCE(q.ocr_text,'') AS ocr_text,q.image_key,q.created_at
+        FROM quizzes q
+        WHERE `+where+`
+        ORDER BY q.id DESC
+        LIMIT ?`, args...); err != nil {
 		return c.Status(500).SendString("db fail")
 	}
 
+	meta := ListMeta{Limit: f.Limit}
+	if len(quizzes) > f.Limit {
+		quizzes = quizzes[:f.Limit]
+		meta.NextCursor = encodeCursor(quizzes[len(quizzes)-1].ID)
+	}
+
+	if err := h.attachAnswers(quizzes); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	if err := h.attachTags(quizzes); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
 	for i := range quizzes {
-		var answers []AnswerRow
-		_ = h.db.Select(&answers, `
-	           SELECT source,answer_text,reason_text,created_at
-	           FROM answers WHERE quiz_id=?
-	           ORDER BY id ASC`, quizzes[i].ID)
-		quizzes[i].Answers = answers
+		quizzes[i].ImagePath = h.imageURL(c.Context(), quizzes[i].ImagePath)
 	}
 
-	return c.JSON(quizzes)
+	return c.JSON(fiber.Map{"data": quizzes, "meta": meta})
+}
+
+type QuizDetail struct {
+	ID        int64         `db:"id" json:"id"`
+	UserID    int64         `db:"user_id" json:"user_id"`
+	Title     string        `db:"title" json:"title"`
+	Status    string        `db:"status" json:"status"`
+	OCRText   string        `db:"ocr_text" json:"ocr_text"`
+	OCRLaTeX  string        `db:"ocr_latex" json:"ocr_latex,omitempty"`
+	OCRMode   string        `db:"ocr_mode" json:"ocr_mode,omitempty"`
+	ImagePath string        `db:"image_key" json:"image_path"`
+	Strategy  string        `db:"strategy" json:"strategy,omitempty"`
+	InputMode string        `db:"input_mode" json:"input_mode,omitempty"`
+	Language  string        `db:"ocr_lang" json:"ocr_lang,omitempty"`
+	PHash     *uint64       `db:"image_phash" json:"-"`
+	Images    []QuizImage   `json:"images"`
+	Tags      []string      `json:"tags"`
+	Similar   []SimilarQuiz `json:"similar"`
+}
+
+// SimilarQuiz is an earlier quiz with a near-identical image
+// ("you've seen this before").
+type SimilarQuiz struct {
+	ID        int64  `db:"id" json:"id"`
+	Title     string `db:"title" json:"title"`
+	Status    string `db:"status" json:"status"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+	Distance  int    `json:"distance"`
 }
 
 func (h *Handler) GetQuiz(c *fiber.Ctx) error {
 	userID := mustUserID(c)
 	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
-	var q struct {
-		ID                         int64
-		UserID                     int64
-		Status, OCRText, ImagePath string
-	}
-	if err := h.db.Get(&q, `SELECT id,user_id,status,ocr_text,image_path FROM quizzes WHERE id=?`, id); err != nil {
+	var q QuizDetail
+	if err := h.db.Get(&q, `
+        SELECT id,user_id,COALESCE(title,'') AS title,status,COALESCE(ocr_text,'') AS ocr_text,
+               COALESCE(ocr_latex,'') AS ocr_latex,COALESCE(ocr_mode,'') AS ocr_mode,image_key,
+               COALESCE(strategy,'') AS strategy,COALESCE(input_mode,'') AS input_mode,COALESCE(ocr_lang,'') AS ocr_lang,image_phash
+        FROM quizzes WHERE id=? AND deleted_at IS NULL`, id); err != nil {
 		return c.Status(404).SendString("not found")
 	}
 	if q.UserID != userID {
 		return c.Status(403).SendString("forbidden")
 	}
+	q.ImagePath = h.imageURL(c.Context(), q.ImagePath)
+	images, err := h.svc.loadImages([]int64{q.ID})
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	q.Images = images[q.ID]
+	if q.Images == nil {
+		q.Images = []QuizImage{}
+	}
+	for i := range q.Images {
+		q.Images[i].ImagePath = h.imageURL(c.Context(), q.Images[i].ImagePath)
+	}
+	tags, err := h.loadTags([]int64{q.ID})
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	q.Tags = tags[q.ID]
+	if q.Tags == nil {
+		q.Tags = []string{}
+	}
+	if q.Similar, err = h.similarQuizzes(q); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
 	return c.JSON(q)
 }
 
@@ -183,19 +399,30 @@ func (h *Handler) ListAnswers(c *fiber.Ctx) error {
 	userID := mustUserID(c)
 	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
 	var owner int64
-	if err := h.db.Get(&owner, `SELECT user_id FROM quizzes WHERE id=?`, id); err != nil {
+	if err := h.db.Get(&owner, `SELECT user_id FROM quizzes WHERE id=? AND deleted_at IS NULL`, id); err != nil {
 		return c.Status(404).SendString("not found")
 	}
 	if owner != userID {
 		return c.Status(403).SendString("forbidden")
 	}
 	var rows []struct {
-		Source    string `db:"source"`
-		Answer    string `db:"answer_text"`
-		Reason    string `db:"reason_text"`
-		CreatedAt string `db:"created_at"`
+		Source        string `db:"source"`
+		QuestionNo    int    `db:"question_no"`
+		Answer        string `db:"answer_text"`
+		Reason        string `db:"reason_text"`
+		PromptVersion string `db:"prompt_version"`
+		ParseMode     string `db:"parse_mode"`
+		InputMode     string `db:"input_mode"`
+		// diagnostics for telling guessed answers from parsed ones
+		ParseConfidence float64         `db:"parse_confidence"`
+		ParseWarnings   json.RawMessage `db:"parse_warnings_json"`
+		RawText         string          `db:"raw_text"`
+		ReasoningText   string          `db:"reasoning_text"`
+		CreatedAt       string          `db:"created_at"`
 	}
-	_ = h.db.Select(&rows, `SELECT source,answer_text,reason_text,created_at FROM answers WHERE quiz_id=? ORDER BY id ASC`, id)
+	_ = h.db.Select(&rows, `SELECT source,question_no,answer_text,reason_text,COALESCE(prompt_version,'') AS prompt_version,COALESCE(parse_mode,'') AS parse_mode,
+		COALESCE(input_mode,'') AS input_mode,COALESCE(parse_confidence,0) AS parse_confidence,COALESCE(parse_warnings_json,'[]') AS parse_warnings_json,
+		COALESCE(raw_text,'') AS raw_text,COALESCE(reasoning_text,'') AS reasoning_text,created_at FROM answers WHERE quiz_id=? ORDER BY question_no ASC, id ASC`, id)
 	return c.JSON(rows)
 }
 
@@ -206,3 +433,58 @@ func mustUserID(c *fiber.Ctx) int64 {
 	}
 	return uid
 }
+
+func (h *Handler) similarQuizzes(q QuizDetail) ([]SimilarQuiz, error) {
+	out := []SimilarQuiz{}
+	// the cover hash says nothing about the other images of the quiz
+	if q.PHash == nil || len(q.Images) != 1 {
+		return out, nil
+	}
+	matches, err := h.svc.SimilarQuizzes(q.UserID, q.ID, *q.PHash, h.cfg.ImgPHashMaxDist, maxSimilarQuizzes)
+	if err != nil || len(matches) == 0 {
+		return out, err
+	}
+
+	ids := make([]int64, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	query, args, err := sqlx.In(`SELECT id,COALESCE(title,'') AS title,status,created_at FROM quizzes WHERE id IN (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []SimilarQuiz
+	if err := h.db.Select(&rows, h.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]SimilarQuiz, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = r
+	}
+	for _, m := range matches {
+		if r, ok := byID[m.ID]; ok {
+			r.Distance = m.Distance
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// imageURL turns an object key into a short-lived signed URL. Callers must
+// have checked quiz ownership first.
+func (h *Handler) imageURL(ctx context.Context, key string) string {
+	u, err := h.blob.SignedURL(ctx, key, h.cfg.StorageURLTTL)
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
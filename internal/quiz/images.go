@@ -0,0 +1,274 @@
+package quiz
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/ocr"
+	"github.com/emandor/lemme_service/internal/telemetry"
+	ws "github.com/emandor/lemme_service/internal/ws"
+)
+
+// maxConcurrentOCR bounds the OCR calls of one quiz running at once.
+const maxConcurrentOCR = 3
+
+// QuizImage is one screenshot of a quiz with its own OCR state.
+type QuizImage struct {
+	ID          int64   `db:"id" json:"-"`
+	Position    int     `db:"position" json:"position"`
+	ImagePath   string  `db:"image_key" json:"image_path"`
+	OriginalKey *string `db:"original_key" json:"-"`
+	Hash        string  `db:"image_hash" json:"-"`
+	Width       *int    `db:"image_width" json:"width"`
+	Height      *int    `db:"image_height" json:"height"`
+	OCRText     string  `db:"ocr_text" json:"ocr_text"`
+	OCRLaTeX    string  `db:"ocr_latex" json:"ocr_latex,omitempty"`
+	OCRStatus   string  `db:"ocr_status" json:"ocr_status"`
+	OCRError    string  `db:"ocr_error" json:"ocr_error,omitempty"`
+}
+
+// uploadFiles returns the ordered "images" files of the form, or the single
+// legacy "image" file.
+func uploadFiles(c *fiber.Ctx, maxImages int) ([]*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, errors.New("image required")
+	}
+	files := form.File["images"]
+	if len(files) == 0 {
+		files = form.File["image"]
+	}
+	if len(files) == 0 {
+		return nil, errors.New("image required")
+	}
+	if len(files) > maxImages {
+		return nil, fmt.Errorf("at most %d images per quiz", maxImages)
+	}
+	return files, nil
+}
+
+// deleteStored removes the blobs of uploads that did not become a quiz.
+func (h *Handler) deleteStored(ctx context.Context, images []storedImage) {
+	for _, im := range images {
+		_ = h.blob.Delete(ctx, im.Key)
+		_ = h.blob.Delete(ctx, im.OriginalKey)
+	}
+}
+
+// CreateQuiz inserts a processing quiz whose cover is images[0] together
+// with its ordered quiz_images rows. An empty strategy, input mode or OCR
+// mode follows the default.
+func (s *Service) CreateQuiz(userID int64, images []storedImage, strategy Strategy, mode InputMode, ocrMode OCRMode, subject string) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cover := images[0]
+	res, err := tx.Exec(`
+        INSERT INTO quizzes
+            (user_id, title, image_key, original_key, crop_json, image_hash, image_phash, image_width, image_height,
+             strategy, input_mode, ocr_mode, subject, status, created_at, updated_at)
+        VALUES (?, NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'processing', NOW(), NOW())`,
+		userID, cover.Key, cover.OriginalKey, cover.cropJSON(), cover.Hash, cover.PHash, cover.Width, cover.Height,
+		nullIfEmpty(string(strategy)), nullIfEmpty(string(mode)), nullIfEmpty(string(ocrMode)), nullIfEmpty(subject))
+	if err != nil {
+		return 0, err
+	}
+	qid, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for i, im := range images {
+		if _, err := tx.Exec(`
+            INSERT INTO quiz_images (quiz_id, position, image_key, original_key, image_hash, image_width, image_height)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			qid, i, im.Key, im.OriginalKey, im.Hash, im.Width, im.Height); err != nil {
+			return 0, err
+		}
+	}
+	return qid, tx.Commit()
+}
+
+func (s *Service) loadImages(quizIDs []int64) (map[int64][]QuizImage, error) {
+	out := map[int64][]QuizImage{}
+	if len(quizIDs) == 0 {
+		return out, nil
+	}
+	query, args, err := sqlx.In(`
+        SELECT quiz_id,id,position,image_key,original_key,image_hash,image_width,image_height,
+               COALESCE(ocr_text,'') AS ocr_text,COALESCE(ocr_latex,'') AS ocr_latex,ocr_status,COALESCE(ocr_error,'') AS ocr_error
+        FROM quiz_images WHERE quiz_id IN (?) ORDER BY quiz_id, position`, quizIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		QuizID int64 `db:"quiz_id"`
+		QuizImage
+	}
+	if err := s.db.Select(&rows, s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.QuizID] = append(out[r.QuizID], r.QuizImage)
+	}
+	return out, nil
+}
+
+// readImages OCRs every image of the quiz concurrently and returns the texts
+// joined in image order, plus their LaTeX variant in math mode. Each image
+// reports its own status over WS, which serializes the writes of the
+// workers; one failing image fails the quiz but the others still finish.
+func (s *Service) readImages(ctx context.Context, quizID int64, mode OCRMode) (text, latex string, err error) {
+	byQuiz, err := s.loadImages([]int64{quizID})
+	if err != nil {
+		return "", "", err
+	}
+	images := byQuiz[quizID]
+	if len(images) == 0 {
+		return "", "", errors.New("quiz has no images")
+	}
+
+	results := make([]ocr.Result, len(images))
+	errs := make([]error, len(images))
+	var g errgroup.Group
+	g.SetLimit(maxConcurrentOCR)
+	for i, im := range images {
+		g.Go(func() error {
+			// recover so that a panicking OCR call fails its image, not the process
+			defer func() {
+				if p := recover(); p != nil {
+					errs[i] = fmt.Errorf("ocr panic: %v", p)
+					log := telemetry.L()
+					log.Error().Int64("quiz_id", quizID).Int("position", im.Position).Interface("panic", p).Msg("ocr_panic")
+					s.setImageStatus(quizID, im, "error", ocr.Result{}, errs[i])
+				}
+			}()
+			results[i], errs[i] = s.readImage(ctx, quizID, im, mode)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return "", "", err
+	}
+
+	texts := make([]string, len(results))
+	latexes := make([]string, len(results))
+	for i, r := range results {
+		texts[i] = r.Text
+		// an image without formulas may come back without a LaTeX variant
+		latexes[i] = cmp.Or(r.LaTeX, r.Text)
+	}
+	if mode != OCRMath {
+		return joinOCR(texts), "", nil
+	}
+	return joinOCR(texts), joinOCR(latexes), nil
+}
+
+// readImage OCRs one image and records its status.
+func (s *Service) readImage(ctx context.Context, quizID int64, im QuizImage, mode OCRMode) (ocr.Result, error) {
+	log := telemetry.L().With().Int64("quiz_id", quizID).Int("position", im.Position).Logger()
+	s.setImageStatus(quizID, im, "processing", ocr.Result{}, nil)
+
+	res, err := s.ocrBlob(ctx, im.ImagePath, im.Hash, mode)
+	if err != nil {
+		log.Error().Err(err).Msg("ocr_fail")
+		s.setImageStatus(quizID, im, "error", ocr.Result{}, err)
+		return ocr.Result{}, err
+	}
+	s.setImageStatus(quizID, im, "done", res, nil)
+	return res, nil
+}
+
+// ocrBlob reads a stored image in mode, using the OCR cache keyed by its
+// hash and the mode. Only math readings carry LaTeX.
+func (s *Service) ocrBlob(ctx context.Context, key, hash string, mode OCRMode) (ocr.Result, error) {
+	log := telemetry.L().With().Str("img", key).Str("ocr_mode", string(mode)).Logger()
+
+	cacheKey := ocrCacheKey(hash, mode)
+	if cached, err := s.rdb.Get(ctx, cacheKey).Result(); err == nil && strings.TrimSpace(cached) != "" {
+		res := ocr.Result{Text: cached}
+		if mode != OCRMath || json.Unmarshal([]byte(cached), &res) == nil {
+			log.Info().Int("len", len(res.Text)).Msg("ocr_cache_hit")
+			return res, nil
+		}
+	}
+	log.Info().Msg("ocr_cache_miss_preprocess")
+
+	data, err := s.blob.Get(ctx, key)
+	if err != nil {
+		return ocr.Result{}, fmt.Errorf("image load: %w", err)
+	}
+
+	// Preprocess for efficient budget usage
+	prep, err := img.PrepareForOCR(data, s.ocrMaxW, s.ocrQuality, s.ocrGray, s.ocrSteps...)
+	if err != nil {
+		return ocr.Result{}, fmt.Errorf("ocr prep: %w", err)
+	}
+
+	// call OCR service with 45s timeout
+	ocrCtx, cancel := context.WithTimeout(ctx, 45*time.Second)
+	defer cancel()
+	read := s.ocr.Read
+	if mode == OCRMath {
+		read = s.ocr.ReadMath
+	}
+	res, err := read(ocrCtx, prep.Bytes, prep.MIME)
+	if err != nil {
+		return ocr.Result{}, err
+	}
+
+	res = ocr.Result{Text: strings.TrimSpace(res.Text), LaTeX: strings.TrimSpace(res.LaTeX)}
+	log.Info().Int("len", len(res.Text)).Int("latex_len", len(res.LaTeX)).Msg("ocr_done")
+
+	if len(res.Text) > 0 && s.ocrCacheTTL > 0 {
+		cached := res.Text
+		if mode == OCRMath {
+			b, _ := json.Marshal(res)
+			cached = string(b)
+		}
+		if err := s.rdb.Set(ctx, cacheKey, cached, s.ocrCacheTTL).Err(); err != nil {
+			log.Warn().Err(err).Msg("ocr_cache_set_err")
+		}
+	}
+	return res, nil
+}
+
+func (s *Service) setImageStatus(quizID int64, im QuizImage, status string, res ocr.Result, err error) {
+	var errText any
+	if err != nil {
+		errText = err.Error()
+	}
+	if status == "done" {
+		_, _ = s.db.Exec(`UPDATE quiz_images SET ocr_status=?, ocr_text=?, ocr_latex=?, ocr_error=NULL WHERE id=?`,
+			status, res.Text, nullIfEmpty(res.LaTeX), im.ID)
+	} else {
+		_, _ = s.db.Exec(`UPDATE quiz_images SET ocr_status=?, ocr_error=? WHERE id=?`, status, errText, im.ID)
+	}
+	ws.BroadcastQuizImageOCR(quizID, im.Position, status, err)
+}
+
+// joinOCR concatenates the per-image texts in order, separated by a blank
+// line so the prompt keeps the screenshots apart.
+func joinOCR(texts []string) string {
+	parts := make([]string, 0, len(texts))
+	for _, t := range texts {
+		if t = strings.TrimSpace(t); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,56 @@
+package quiz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/emandor/lemme_service/internal/ocr"
+)
+
+// panicOCR reads the size of an image as its text and panics on a 2px wide
+// one.
+type panicOCR struct{}
+
+func (panicOCR) Read(_ context.Context, b []byte, _ string) (ocr.Result, error) {
+	im, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return ocr.Result{}, err
+	}
+	if im.Bounds().Dx() == 2 {
+		panic("decoder bug")
+	}
+	return ocr.Result{Text: fmt.Sprint("width ", im.Bounds().Dx())}, nil
+}
+
+func (o panicOCR) ReadMath(ctx context.Context, b []byte, mime string) (ocr.Result, error) {
+	return o.Read(ctx, b, mime)
+}
+
+func TestReadImagesRecoversPanic(t *testing.T) {
+	s := testService(t)
+	s.ocr = panicOCR{}
+	ctx := context.Background()
+	user := seedUser(t, s.db)
+	quiz := seedQuiz(t, s.db, user, "shot", 3)
+
+	for i, key := range []string{"quizzes/shot.jpg", "quizzes/shot-1.jpg", "quizzes/shot-2.jpg"} {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, i+1, 1)))
+		s.blob.Put(ctx, key, buf.Bytes(), "image/png")
+	}
+
+	_, _, err := s.readImages(ctx, quiz, OCRPlain)
+	if err == nil || !strings.Contains(err.Error(), "ocr panic: decoder bug") {
+		t.Fatalf("readImages error %v", err)
+	}
+	var statuses []string
+	s.db.Select(&statuses, `SELECT ocr_status FROM quiz_images WHERE quiz_id=? ORDER BY position`, quiz)
+	if strings.Join(statuses, ",") != "done,error,done" {
+		t.Errorf("statuses %v", statuses)
+	}
+}
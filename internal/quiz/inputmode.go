@@ -0,0 +1,99 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/ocr"
+	"github.com/emandor/lemme_service/internal/providers"
+)
+
+// InputMode decides what the providers are shown of a quiz.
+type InputMode string
+
+const (
+	// InputText sends the OCR text only.
+	InputText InputMode = "text"
+	// InputVision skips OCR and sends the prepared images only; for
+	// diagrams, charts and geometry that OCR cannot capture.
+	InputVision InputMode = "vision"
+	// InputHybrid sends the images together with their OCR text.
+	InputHybrid InputMode = "hybrid"
+)
+
+// parseInputMode validates a configured or requested mode; empty means
+// "use the default".
+func parseInputMode(v string) (InputMode, error) {
+	switch m := InputMode(v); m {
+	case "", InputText, InputVision, InputHybrid:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown input mode %q", v)
+}
+
+// quizInputMode is the mode stored on the quiz, or the configured one.
+func (s *Service) quizInputMode(quizID int64) InputMode {
+	var v string
+	_ = s.db.Get(&v, `SELECT COALESCE(input_mode,'') FROM quizzes WHERE id=?`, quizID)
+	if m, err := parseInputMode(v); err == nil && m != "" {
+		return m
+	}
+	return s.inputMode
+}
+
+// promptImages loads the quiz images in order, prepared as for OCR.
+func (s *Service) promptImages(ctx context.Context, quizID int64) ([]providers.Image, error) {
+	byQuiz, err := s.loadImages([]int64{quizID})
+	if err != nil {
+		return nil, err
+	}
+	images := byQuiz[quizID]
+	if len(images) == 0 {
+		return nil, errors.New("quiz has no images")
+	}
+
+	out := make([]providers.Image, len(images))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentOCR)
+	for i, im := range images {
+		g.Go(func() error {
+			data, err := s.blob.Get(gctx, im.ImagePath)
+			if err != nil {
+				return fmt.Errorf("image load: %w", err)
+			}
+			prep, err := img.PrepareForOCR(data, s.ocrMaxW, s.ocrQuality, s.ocrGray, s.ocrSteps...)
+			if err != nil {
+				return fmt.Errorf("image prep: %w", err)
+			}
+			out[i] = providers.Image{Data: prep.Bytes, MIME: prep.MIME}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// imageClients are the providers that accept images.
+func imageClients(clients []providers.Client) []providers.Client {
+	var out []providers.Client
+	for _, c := range clients {
+		if v, ok := c.(providers.ImageAsker); ok && v.AcceptsImages() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// skipOCR marks the images of a vision quiz as not read.
+func (s *Service) skipOCR(quizID int64) {
+	byQuiz, _ := s.loadImages([]int64{quizID})
+	for _, im := range byQuiz[quizID] {
+		s.setImageStatus(quizID, im, "skipped", ocr.Result{}, nil)
+	}
+}
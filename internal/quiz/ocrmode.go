@@ -0,0 +1,45 @@
+package quiz
+
+import "fmt"
+
+// OCRMode decides how quiz images are read.
+type OCRMode string
+
+const (
+	// OCRPlain reads plain text.
+	OCRPlain OCRMode = "plain"
+	// OCRMath also reads the text with formulas as LaTeX, which keeps
+	// fractions, exponents and roots intact for the providers.
+	OCRMath OCRMode = "math"
+)
+
+// ocrModes are all the modes, each with its own OCR cache.
+var ocrModes = []OCRMode{OCRPlain, OCRMath}
+
+// parseOCRMode validates a configured or requested mode; empty means "use
+// the default".
+func parseOCRMode(v string) (OCRMode, error) {
+	switch m := OCRMode(v); m {
+	case "", OCRPlain, OCRMath:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown OCR mode %q", v)
+}
+
+// quizOCRMode is the mode stored on the quiz, or the configured one.
+func (s *Service) quizOCRMode(quizID int64) OCRMode {
+	var v string
+	_ = s.db.Get(&v, `SELECT COALESCE(ocr_mode,'') FROM quizzes WHERE id=?`, quizID)
+	if m, err := parseOCRMode(v); err == nil && m != "" {
+		return m
+	}
+	return s.ocrMode
+}
+
+// ocrCacheKey keeps the readings of each mode apart.
+func ocrCacheKey(hash string, mode OCRMode) string {
+	if mode == OCRMath {
+		return "ocr:math:" + hash
+	}
+	return "ocr:" + hash
+}
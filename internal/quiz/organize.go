@@ -0,0 +1,279 @@
+package quiz
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	maxTitleLen  = 191
+	maxTagLen    = 64
+	maxFolderLen = 191
+	maxQuizTags  = 20
+)
+
+// ownedQuizID parses :id and checks that the quiz belongs to the caller.
+func (h *Handler) ownedQuizID(c *fiber.Ctx) (int64, error) {
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	var owner int64
+	if err := h.db.Get(&owner, `SELECT user_id FROM quizzes WHERE id=? AND deleted_at IS NULL`, id); err != nil {
+		return 0, fiber.NewError(fiber.StatusNotFound, "not found")
+	}
+	if owner != mustUserID(c) {
+		return 0, fiber.NewError(fiber.StatusForbidden, "forbidden")
+	}
+	return id, nil
+}
+
+// SetTitle sets (or clears, with an empty title) the quiz title.
+func (h *Handler) SetTitle(c *fiber.Ctx) error {
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Title string `json:"title"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+
+	title := cleanTitle(body.Title)
+	if _, err := h.db.Exec(`UPDATE quizzes SET title=NULLIF(?,''), updated_at=NOW() WHERE id=?`, title, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(fiber.Map{"id": id, "title": title})
+}
+
+// GenerateTitle derives a title from the OCR text with a provider call.
+func (h *Handler) GenerateTitle(c *fiber.Ctx) error {
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	var txt sql.NullString
+	if err := h.db.Get(&txt, `SELECT ocr_text FROM quizzes WHERE id=?`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	if strings.TrimSpace(txt.String) == "" {
+		return c.Status(409).SendString("ocr not ready")
+	}
+
+	title, err := h.svc.GenerateTitle(c.Context(), txt.String)
+	if err != nil || title == "" {
+		return c.Status(502).SendString("title generation failed")
+	}
+	if _, err := h.db.Exec(`UPDATE quizzes SET title=?, updated_at=NOW() WHERE id=?`, title, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(fiber.Map{"id": id, "title": title})
+}
+
+type LabelRow struct {
+	ID        int64  `db:"id" json:"id"`
+	Name      string `db:"name" json:"name"`
+	QuizCount int    `db:"quiz_count" json:"quiz_count"`
+}
+
+func (h *Handler) ListTags(c *fiber.Ctx) error {
+	rows := []LabelRow{}
+	if err := h.db.Select(&rows, `
+        SELECT t.id,t.name,COUNT(q.id) AS quiz_count
+        FROM tags t
+        LEFT JOIN quiz_tags qt ON qt.tag_id=t.id
+        LEFT JOIN quizzes q ON q.id=qt.quiz_id AND q.deleted_at IS NULL
+        WHERE t.user_id=? GROUP BY t.id,t.name ORDER BY t.name`, mustUserID(c)); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(rows)
+}
+
+func (h *Handler) CreateTag(c *fiber.Ctx) error {
+	return h.createLabel(c, "tags", maxTagLen)
+}
+
+func (h *Handler) DeleteTag(c *fiber.Ctx) error {
+	return h.deleteLabel(c, "tags")
+}
+
+// SetQuizTags replaces the tags of a quiz. Unknown tag names are created.
+func (h *Handler) SetQuizTags(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+	names := cleanLabels(body.Tags, maxTagLen)
+	if len(names) > maxQuizTags {
+		return c.Status(400).SendString("too many tags")
+	}
+
+	tx, err := h.db.Beginx()
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM quiz_tags WHERE quiz_id=?`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	for _, n := range names {
+		if _, err := tx.Exec(`INSERT IGNORE INTO tags(user_id,name) VALUES(?,?)`, userID, n); err != nil {
+			return c.Status(500).SendString("db fail")
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO quiz_tags(quiz_id,tag_id)
+            SELECT ?, id FROM tags WHERE user_id=? AND name=?`, id, userID, n); err != nil {
+			return c.Status(500).SendString("db fail")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(fiber.Map{"id": id, "tags": names})
+}
+
+func (h *Handler) ListFolders(c *fiber.Ctx) error {
+	rows := []LabelRow{}
+	if err := h.db.Select(&rows, `
+        SELECT f.id,f.name,COUNT(q.id) AS quiz_count
+        FROM folders f
+        LEFT JOIN quiz_folders qf ON qf.folder_id=f.id
+        LEFT JOIN quizzes q ON q.id=qf.quiz_id AND q.deleted_at IS NULL
+        WHERE f.user_id=? GROUP BY f.id,f.name ORDER BY f.name`, mustUserID(c)); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(rows)
+}
+
+func (h *Handler) CreateFolder(c *fiber.Ctx) error {
+	return h.createLabel(c, "folders", maxFolderLen)
+}
+
+func (h *Handler) DeleteFolder(c *fiber.Ctx) error {
+	return h.deleteLabel(c, "folders")
+}
+
+// SetQuizFolders replaces the folders a quiz belongs to.
+func (h *Handler) SetQuizFolders(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		FolderIDs []int64 `json:"folder_ids"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+
+	folderIDs := []int64{}
+	if len(body.FolderIDs) > 0 {
+		q, args, err := sqlx.In(`SELECT id FROM folders WHERE user_id=? AND id IN (?)`, userID, body.FolderIDs)
+		if err != nil {
+			return c.Status(500).SendString("db fail")
+		}
+		if err := h.db.Select(&folderIDs, h.db.Rebind(q), args...); err != nil {
+			return c.Status(500).SendString("db fail")
+		}
+		if len(folderIDs) != len(dedupeIDs(body.FolderIDs)) {
+			return c.Status(404).SendString("folder not found")
+		}
+	}
+
+	tx, err := h.db.Beginx()
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM quiz_folders WHERE quiz_id=?`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	for _, fid := range folderIDs {
+		if _, err := tx.Exec(`INSERT INTO quiz_folders(quiz_id,folder_id) VALUES(?,?)`, id, fid); err != nil {
+			return c.Status(500).SendString("db fail")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(fiber.Map{"id": id, "folder_ids": folderIDs})
+}
+
+// createLabel inserts a named row into tags or folders for the caller.
+func (h *Handler) createLabel(c *fiber.Ctx, table string, maxLen int) error {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).SendString("invalid body")
+	}
+	names := cleanLabels([]string{body.Name}, maxLen)
+	if len(names) == 0 {
+		return c.Status(400).SendString("name required")
+	}
+
+	res, err := h.db.Exec(`INSERT INTO `+table+`(user_id,name) VALUES(?,?)`, mustUserID(c), names[0])
+	if err != nil {
+		return c.Status(409).SendString("already exists")
+	}
+	id, _ := res.LastInsertId()
+	return c.Status(201).JSON(LabelRow{ID: id, Name: names[0]})
+}
+
+// deleteLabel removes one of the caller's tags or folders; links cascade.
+func (h *Handler) deleteLabel(c *fiber.Ctx, table string) error {
+	id, _ := strconv.ParseInt(c.Params("labelID"), 10, 64)
+	res, err := h.db.Exec(`DELETE FROM `+table+` WHERE id=? AND user_id=?`, id, mustUserID(c))
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(404).SendString("not found")
+	}
+	return c.SendStatus(204)
+}
+
+// cleanLabels trims, collapses whitespace, drops empty or too long names and
+// removes case-insensitive duplicates.
+func cleanLabels(in []string, maxLen int) []string {
+	out := []string{}
+	seen := map[string]struct{}{}
+	for _, n := range in {
+		n = strings.Join(strings.Fields(n), " ")
+		if n == "" || len([]rune(n)) > maxLen {
+			continue
+		}
+		k := strings.ToLower(n)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, n)
+	}
+	return out
+}
+
+func dedupeIDs(ids []int64) []int64 {
+	seen := map[int64]struct{}{}
+	out := ids[:0:0]
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,210 @@
+package quiz
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emandor/lemme_service/internal/providers"
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// maxSubjectLen fits quizzes.subject.
+const maxSubjectLen = 64
+
+// promptStore holds the prompt templates per kind, loaded from
+// PROMPT_TEMPLATE_DIR (<kind>/<version>.tmpl, or <kind>/<lang>/<version>.tmpl
+// for one language) and the prompt_templates table. The builtin prompt stays
+// in the pool; it only gets weight when nothing else is configured for its
+// kind, unless PROMPT_WEIGHTS says so.
+type promptStore struct {
+	db      *sqlx.DB
+	dir     string
+	weights map[string]int // "<kind>[/<lang>]/<version>" -> weight
+
+	mu     sync.RWMutex
+	byKind map[providers.PromptKind][]*providers.PromptTemplate
+}
+
+func newPromptStore(db *sqlx.DB, dir string, weights []string) *promptStore {
+	p := &promptStore{db: db, dir: dir, weights: map[string]int{}}
+	for _, w := range weights {
+		k, v, ok := strings.Cut(strings.TrimSpace(w), "=")
+		if n, err := strconv.Atoi(v); ok && err == nil {
+			p.weights[k] = n
+		}
+	}
+	return p
+}
+
+// load replaces the templates. A template that fails to parse is skipped;
+// when reading fails the previous set stays in place.
+func (p *promptStore) load() error {
+	log := telemetry.L().With().Str("component", "prompts").Logger()
+	found := map[string]*providers.PromptTemplate{}
+	add := func(kind providers.PromptKind, code, version, body string, weight int) {
+		t, err := providers.ParsePromptTemplate(kind, version, body, weight)
+		if err != nil {
+			log.Warn().Err(err).Str("kind", string(kind)).Str("version", version).Msg("prompt_template_invalid")
+			return
+		}
+		t.Lang = code
+		found[templateKey(t)] = t
+	}
+
+	if p.dir != "" {
+		for _, pattern := range []string{"*/*.tmpl", "*/*/*.tmpl"} {
+			paths, err := filepath.Glob(filepath.Join(p.dir, pattern))
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				body, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(p.dir, path)
+				parts := strings.Split(filepath.ToSlash(rel), "/")
+				version := strings.TrimSuffix(parts[len(parts)-1], ".tmpl")
+				code := ""
+				if len(parts) == 3 {
+					code = parts[1]
+				}
+				add(providers.PromptKind(parts[0]), code, version, string(body), 1)
+			}
+		}
+	}
+
+	var rows []struct {
+		Kind    string `db:"kind"`
+		Lang    string `db:"lang"`
+		Version string `db:"version"`
+		Body    string `db:"body"`
+		Weight  int    `db:"weight"`
+	}
+	if err := p.db.Select(&rows, `SELECT kind,lang,version,body,weight FROM prompt_templates WHERE active=1 ORDER BY id`); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		add(providers.PromptKind(r.Kind), r.Lang, r.Version, r.Body, r.Weight)
+	}
+
+	byKind := map[providers.PromptKind][]*providers.PromptTemplate{}
+	for key, t := range found {
+		if w, ok := p.weights[key]; ok {
+			t.Weight = w
+		}
+		byKind[t.Kind] = append(byKind[t.Kind], t)
+	}
+	for _, kind := range []providers.PromptKind{providers.PromptSingle, providers.PromptMulti} {
+		ts := byKind[kind]
+		// stable order keeps the A/B assignment stable across reloads
+		sort.Slice(ts, func(i, j int) bool { return templateKey(ts[i]) < templateKey(ts[j]) })
+		builtin := *providers.BuiltinPrompt(kind)
+		builtin.Weight = 1
+		for _, t := range ts {
+			if t.Lang == "" {
+				builtin.Weight = 0
+			}
+		}
+		if w, ok := p.weights[string(kind)+"/"+builtin.Version]; ok {
+			builtin.Weight = w
+		}
+		byKind[kind] = append([]*providers.PromptTemplate{&builtin}, ts...)
+	}
+
+	p.mu.Lock()
+	p.byKind = byKind
+	p.mu.Unlock()
+	return nil
+}
+
+// start reloads the templates every interval so edits need no deploy.
+func (p *promptStore) start(interval time.Duration) {
+	log := telemetry.L().With().Str("component", "prompts").Logger()
+	if err := p.load(); err != nil {
+		log.Error().Err(err).Msg("prompt_load_fail")
+	}
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for range t.C {
+			if err := p.load(); err != nil {
+				log.Error().Err(err).Msg("prompt_load_fail")
+			}
+		}
+	}()
+}
+
+// pick returns the template version assigned to quizID, preferring the
+// templates of language code over the language-independent ones.
+func (p *promptStore) pick(kind providers.PromptKind, code string, quizID int64) *providers.PromptTemplate {
+	p.mu.RLock()
+	ts := p.byKind[kind]
+	p.mu.RUnlock()
+
+	var own, common []*providers.PromptTemplate
+	for _, t := range ts {
+		switch t.Lang {
+		case "":
+			common = append(common, t)
+		case code:
+			own = append(own, t)
+		}
+	}
+	if t := providers.PickPrompt(own, quizID); t != nil {
+		return t
+	}
+	if t := providers.PickPrompt(common, quizID); t != nil {
+		return t
+	}
+	return providers.BuiltinPrompt(kind)
+}
+
+func templateKey(t *providers.PromptTemplate) string {
+	if t.Lang == "" {
+		return string(t.Kind) + "/" + t.Version
+	}
+	return string(t.Kind) + "/" + t.Lang + "/" + t.Version
+}
+
+// renderPrompt renders the prompt of kind assigned to quizID and returns it
+// with the template version. A failing template falls back to the builtin.
+func (s *Service) renderPrompt(quizID int64, kind providers.PromptKind, vars providers.PromptVars) (string, string) {
+	t := providers.BuiltinPrompt(kind)
+	if s.prompts != nil {
+		t = s.prompts.pick(kind, vars.Language, quizID)
+	}
+	out, err := t.Render(vars)
+	if err != nil {
+		log := telemetry.L()
+		log.Warn().Err(err).Int64("quiz_id", quizID).Str("version", t.Version).Msg("prompt_render_fail")
+		t = providers.BuiltinPrompt(kind)
+		out, _ = t.Render(vars)
+	}
+	return out, t.Version
+}
+
+// promptContext returns the detected language and subject of a quiz and
+// the answer language preferred by its owner.
+func (s *Service) promptContext(quizID int64) (code, subject, answerLang string) {
+	var row struct {
+		Lang       string `db:"ocr_lang"`
+		Subject    string `db:"subject"`
+		AnswerLang string `db:"answer_lang"`
+	}
+	_ = s.db.Get(&row, `
+        SELECT COALESCE(q.ocr_lang,'') AS ocr_lang, COALESCE(q.subject,'') AS subject,
+               COALESCE(u.answer_lang,'') AS answer_lang
+        FROM quizzes q JOIN users u ON u.id=q.user_id WHERE q.id=?`, quizID)
+	return row.Lang, row.Subject, row.AnswerLang
+}
@@ -0,0 +1,218 @@
+package quiz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+const purgeBatchSize = 100
+
+// DeleteQuiz soft-deletes a quiz. It can be restored until the restore
+// window passes, after which the purge job removes it for good.
+func (h *Handler) DeleteQuiz(c *fiber.Ctx) error {
+	id, err := h.ownedQuizID(c)
+	if err != nil {
+		return err
+	}
+	if _, err := h.db.Exec(`UPDATE quizzes SET deleted_at=NOW() WHERE id=? AND deleted_at IS NULL`, id); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	return c.JSON(fiber.Map{
+		"id":            id,
+		"deleted":       true,
+		"restore_until": time.Now().Add(h.cfg.QuizRestoreWindow).UTC().Format(time.RFC3339),
+	})
+}
+
+// RestoreQuiz undoes a soft delete that is still inside the restore window.
+func (h *Handler) RestoreQuiz(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+
+	var owner int64
+	if err := h.db.Get(&owner, `SELECT user_id FROM quizzes WHERE id=? AND deleted_at IS NOT NULL`, id); err != nil {
+		return c.Status(404).SendString("not found")
+	}
+	if owner != userID {
+		return c.Status(403).SendString("forbidden")
+	}
+
+	res, err := h.db.Exec(`
+        UPDATE quizzes SET deleted_at=NULL
+        WHERE id=? AND deleted_at > NOW() - INTERVAL ? SECOND`,
+		id, int64(h.cfg.QuizRestoreWindow/time.Second))
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(410).SendString("restore window expired")
+	}
+	return c.JSON(fiber.Map{"id": id, "deleted": false})
+}
+
+// StartPurgeJob periodically hard-deletes quizzes whose restore window passed.
+func (h *Handler) StartPurgeJob() {
+	interval := h.cfg.QuizPurgeInterval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		log := telemetry.L().With().Str("job", "quiz_purge").Logger()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			n, err := h.svc.PurgeDeleted(context.Background(), h.cfg.QuizRestoreWindow)
+			if err != nil {
+				log.Error().Err(err).Msg("purge_fail")
+			} else if n > 0 {
+				log.Info().Int("quizzes", n).Msg("purge_done")
+			}
+			<-t.C
+		}
+	}()
+}
+
+// PurgeDeleted removes quizzes soft-deleted more than window ago together
+// with their answers, images, provider logs, stored files and OCR cache
+// entries, and the batches (with their PDF) whose last quiz they were.
+func (s *Service) PurgeDeleted(ctx context.Context, window time.Duration) (int, error) {
+	log := telemetry.L().With().Str("job", "quiz_purge").Logger()
+	purged := 0
+	for {
+		var rows []struct {
+			ID      int64  `db:"id"`
+			BatchID *int64 `db:"batch_id"`
+		}
+		if err := s.db.Select(&rows, `
+            SELECT id, batch_id FROM quizzes
+            WHERE deleted_at IS NOT NULL AND deleted_at < NOW() - INTERVAL ? SECOND
+            ORDER BY id LIMIT ?`, int64(window/time.Second), purgeBatchSize); err != nil {
+			return purged, err
+		}
+
+		for _, r := range rows {
+			keys, hashes, err := s.imageKeys(r.ID)
+			if err != nil {
+				return purged, err
+			}
+			if err := s.purgeQuiz(r.ID); err != nil {
+				return purged, err
+			}
+			purged++
+
+			for _, key := range keys {
+				// batch quizzes share the page image they start on
+				var others int
+				if err := s.db.Get(&others, `SELECT COUNT(*) FROM quiz_images WHERE image_key=?`, key); err != nil || others > 0 {
+					continue
+				}
+				if err := s.blob.Delete(ctx, key); err != nil {
+					log.Warn().Err(err).Int64("quiz_id", r.ID).Str("key", key).Msg("purge_image_fail")
+				}
+			}
+
+			// the OCR cache is keyed by hash and mode, keep it while another quiz uses it
+			for _, hash := range hashes {
+				var others int
+				if err := s.db.Get(&others, `SELECT COUNT(*) FROM quiz_images WHERE image_hash=?`, hash); err == nil && others == 0 {
+					for _, mode := range ocrModes {
+						s.rdb.Del(ctx, ocrCacheKey(hash, mode))
+					}
+				}
+			}
+
+			if r.BatchID != nil {
+				if err := s.purgeBatch(ctx, *r.BatchID); err != nil {
+					return purged, err
+				}
+			}
+		}
+
+		if len(rows) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (s *Service) purgeQuiz(quizID int64) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		`DELETE FROM answers WHERE quiz_id=?`,
+		`DELETE FROM quiz_images WHERE quiz_id=?`,
+		`DELETE FROM providers_logs WHERE quiz_id=?`,
+		`DELETE FROM quizzes WHERE id=?`,
+	} {
+		if _, err := tx.Exec(q, quizID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// purgeBatch removes a finished batch and its source PDF once none of its
+// quizzes is left.
+func (s *Service) purgeBatch(ctx context.Context, batchID int64) error {
+	var sourceKey string
+	err := s.db.Get(&sourceKey, `
+        SELECT source_key FROM quiz_batches b
+        WHERE id=? AND status<>'processing'
+          AND NOT EXISTS (SELECT 1 FROM quizzes q WHERE q.batch_id=b.id)`, batchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM quiz_batches WHERE id=?`, batchID); err != nil {
+		return err
+	}
+	if err := s.blob.Delete(ctx, sourceKey); err != nil {
+		log := telemetry.L()
+		log.Warn().Err(err).Int64("batch_id", batchID).Str("key", sourceKey).Msg("purge_batch_source_fail")
+	}
+	return nil
+}
+
+// imageKeys lists the stored objects and image hashes of a quiz: every
+// quiz image with its original, plus the cover in case it differs.
+func (s *Service) imageKeys(quizID int64) ([]string, []string, error) {
+	var rows []struct {
+		ImageKey    string  `db:"image_key"`
+		OriginalKey *string `db:"original_key"`
+		Hash        string  `db:"image_hash"`
+	}
+	if err := s.db.Select(&rows, `
+        SELECT image_key,original_key,image_hash FROM quizzes WHERE id=?
+        UNION
+        SELECT image_key,original_key,image_hash FROM quiz_images WHERE quiz_id=?`, quizID, quizID); err != nil {
+		return nil, nil, err
+	}
+	seen := map[string]bool{}
+	var keys, hashes []string
+	add := func(list *[]string, v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			*list = append(*list, v)
+		}
+	}
+	for _, r := range rows {
+		add(&keys, r.ImageKey)
+		if r.OriginalKey != nil {
+			add(&keys, *r.OriginalKey)
+		}
+		add(&hashes, r.Hash)
+	}
+	return keys, hashes, nil
+}
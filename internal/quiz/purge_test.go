@@ -0,0 +1,93 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/storage"
+)
+
+func TestPurgeDeleted(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	user := seedUser(t, s.db)
+
+	res := s.db.MustExec(`INSERT INTO quiz_batches (user_id, source_key, status) VALUES (?, 'batches/1.pdf', 'ready')`, user)
+	batch, _ := res.LastInsertId()
+	res = s.db.MustExec(`INSERT INTO quiz_batches (user_id, source_key, status) VALUES (?, 'batches/2.pdf', 'processing')`, user)
+	running, _ := res.LastInsertId()
+
+	first := seedQuiz(t, s.db, user, "first", 1)
+	second := seedQuiz(t, s.db, user, "second", 1)
+	late := seedQuiz(t, s.db, user, "late", 1)
+	kept := seedQuiz(t, s.db, user, "first", 1) // shares its image hash with first
+	s.db.MustExec(`UPDATE quizzes SET batch_id=?, batch_position=0 WHERE id IN (?, ?)`, batch, first, second)
+	s.db.MustExec(`UPDATE quizzes SET batch_id=?, batch_position=0 WHERE id=?`, running, late)
+	seedAnswer(t, s.db, first, "OPENAI", "A")
+
+	for _, key := range []string{"batches/1.pdf", "batches/2.pdf", "quizzes/second.jpg", "quizzes/late.jpg"} {
+		s.blob.Put(ctx, key, []byte("x"), "")
+	}
+	for _, hash := range []string{"first", "second"} {
+		for _, mode := range ocrModes {
+			s.rdb.Set(ctx, ocrCacheKey(hash, mode), "{}", 0)
+		}
+	}
+
+	deleteQuizzes := func(ids ...int64) {
+		for _, id := range ids {
+			s.db.MustExec(`UPDATE quizzes SET deleted_at=NOW() - INTERVAL 2 DAY WHERE id=?`, id)
+		}
+	}
+	exists := func(table string, id int64) bool {
+		var n int
+		s.db.Get(&n, `SELECT COUNT(*) FROM `+table+` WHERE id=?`, id)
+		return n > 0
+	}
+	stored := func(key string) bool {
+		_, err := s.blob.Get(ctx, key)
+		return !errors.Is(err, storage.ErrNotFound)
+	}
+	cached := func(hash string) bool {
+		for _, mode := range ocrModes {
+			if n, _ := s.rdb.Exists(ctx, ocrCacheKey(hash, mode)).Result(); n > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	// one child left: the batch and its PDF stay
+	deleteQuizzes(first)
+	if n, err := s.PurgeDeleted(ctx, time.Hour); err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v", n, err)
+	}
+	if exists("quizzes", first) || !exists("quiz_batches", batch) || !stored("batches/1.pdf") {
+		t.Error("batch purged while a quiz of it is left")
+	}
+	if !cached("first") {
+		t.Error("OCR cache of an image still in use purged")
+	}
+
+	// the last child takes the batch, its PDF and the OCR cache with it
+	deleteQuizzes(second, late)
+	if n, err := s.PurgeDeleted(ctx, time.Hour); err != nil || n != 2 {
+		t.Fatalf("PurgeDeleted = %d, %v", n, err)
+	}
+	if exists("quiz_batches", batch) || stored("batches/1.pdf") {
+		t.Error("batch or its PDF left after its last quiz")
+	}
+	if stored("quizzes/second.jpg") || cached("second") {
+		t.Error("image or OCR cache of a purged quiz left")
+	}
+
+	// a batch still splitting keeps its PDF
+	if !exists("quiz_batches", running) || !stored("batches/2.pdf") {
+		t.Error("processing batch purged")
+	}
+	if !exists("quizzes", kept) {
+		t.Error("live quiz purged")
+	}
+}
@@ -0,0 +1,248 @@
+package quiz
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/emandor/lemme_service/internal/providers"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var validStatuses = map[string]struct{}{
+	"uploaded": {}, "processing": {}, "completed": {}, "error": {},
+}
+
+var validSources = map[providers.SourceName]struct{}{
+	providers.SourceOpenAI: {}, providers.SourceClaude: {}, providers.SourceGemini: {},
+}
+
+// listFilter holds the parsed query string of ListMyQuizzes.
+type listFilter struct {
+	Limit    int
+	BeforeID int64
+	Status   string
+	Provider providers.SourceName
+	From, To time.Time
+	Tag      string
+	FolderID int64
+}
+
+func parseListFilter(c *fiber.Ctx) (listFilter, error) {
+	f := listFilter{Limit: c.QueryInt("limit", defaultListLimit)}
+	if f.Limit <= 0 || f.Limit > maxListLimit {
+		f.Limit = defaultListLimit
+	}
+
+	if cur := c.Query("cursor"); cur != "" {
+		id, err := decodeCursor(cur)
+		if err != nil {
+			return f, errors.New("invalid cursor")
+		}
+		f.BeforeID = id
+	}
+
+	if st := strings.ToLower(c.Query("status")); st != "" {
+		if _, ok := validStatuses[st]; !ok {
+			return f, errors.New("invalid status")
+		}
+		f.Status = st
+	}
+
+	if p := providers.SourceName(strings.ToUpper(c.Query("provider"))); p != "" {
+		if _, ok := validSources[p]; !ok {
+			return f, errors.New("invalid provider")
+		}
+		f.Provider = p
+	}
+
+	f.Tag = strings.TrimSpace(c.Query("tag"))
+	if v := c.Query("folder"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return f, errors.New("invalid folder")
+		}
+		f.FolderID = id
+	}
+
+	var err error
+	if f.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		return f, errors.New("invalid from")
+	}
+	if f.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		return f, errors.New("invalid to")
+	}
+	return f, nil
+}
+
+// where builds the WHERE clause (quizzes aliased as q) and its args.
+func (f listFilter) where(userID int64) (string, []any) {
+	conds := []string{"q.user_id=?", "q.deleted_at IS NULL"}
+	args := []any{userID}
+
+	if f.BeforeID > 0 {
+		conds = append(conds, "q.id<?")
+		args = append(args, f.BeforeID)
+	}
+	if f.Status != "" {
+		conds = append(conds, "q.status=?")
+		args = append(args, f.Status)
+	}
+	if f.Provider != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM answers a WHERE a.quiz_id=q.id AND a.source=? AND a.answer_text<>'ERROR')")
+		args = append(args, f.Provider)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "q.created_at>=?")
+		args = append(args, f.From.Format(time.DateTime))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "q.created_at<?")
+		args = append(args, f.To.Format(time.DateTime))
+	}
+	if f.Tag != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM quiz_tags qt JOIN tags t ON t.id=qt.tag_id WHERE qt.quiz_id=q.id AND t.name=?)")
+		args = append(args, f.Tag)
+	}
+	if f.FolderID > 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM quiz_folders qf WHERE qf.quiz_id=q.id AND qf.folder_id=?)")
+		args = append(args, f.FolderID)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// parseDateParam accepts RFC3339 or YYYY-MM-DD. A bare date used as an upper
+// bound is moved to the next day so that "to" is inclusive.
+func parseDateParam(v string, upper bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(s string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("bad cursor")
+	}
+	return id, nil
+}
+
+// attachAnswers loads the answers of all given quizzes in one query.
+func (h *Handler) attachAnswers(quizzes []QuizRow) error {
+	ids := make([]int64, len(quizzes))
+	for i := range quizzes {
+		ids[i] = quizzes[i].ID
+	}
+	byQuiz, err := h.loadAnswers(ids)
+	if err != nil {
+		return err
+	}
+	for i := range quizzes {
+		quizzes[i].Answers = byQuiz[quizzes[i].ID]
+		if quizzes[i].Answers == nil {
+			quizzes[i].Answers = []AnswerRow{}
+		}
+	}
+	return nil
+}
+
+// loadAnswers returns the answers of the given quizzes keyed by quiz id.
+func (h *Handler) loadAnswers(ids []int64) (map[int64][]AnswerRow, error) {
+	out := make(map[int64][]AnswerRow, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	q, args, err := sqlx.In(`
+        SELECT quiz_id,source,question_no,answer_text,COALESCE(reason_text,'') AS reason_text,
+               COALESCE(prompt_version,'') AS prompt_version,COALESCE(parse_mode,'') AS parse_mode,
+               COALESCE(parse_confidence,0) AS parse_confidence,COALESCE(input_mode,'') AS input_mode,created_at
+        FROM answers WHERE quiz_id IN (?)
+        ORDER BY question_no ASC, id ASC`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var answers []AnswerRow
+	if err := h.db.Select(&answers, h.db.Rebind(q), args...); err != nil {
+		return nil, err
+	}
+	for _, a := range answers {
+		out[a.QuizID] = append(out[a.QuizID], a)
+	}
+	return out, nil
+}
+
+// attachTags loads the tag names of all given quizzes in one query.
+func (h *Handler) attachTags(quizzes []QuizRow) error {
+	ids := make([]int64, len(quizzes))
+	for i := range quizzes {
+		ids[i] = quizzes[i].ID
+	}
+	byQuiz, err := h.loadTags(ids)
+	if err != nil {
+		return err
+	}
+	for i := range quizzes {
+		quizzes[i].Tags = byQuiz[quizzes[i].ID]
+		if quizzes[i].Tags == nil {
+			quizzes[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// loadTags returns tag names of the given quizzes keyed by quiz id.
+func (h *Handler) loadTags(ids []int64) (map[int64][]string, error) {
+	out := make(map[int64][]string, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	q, args, err := sqlx.In(`
+        SELECT qt.quiz_id,t.name
+        FROM quiz_tags qt JOIN tags t ON t.id=qt.tag_id
+        WHERE qt.quiz_id IN (?)
+        ORDER BY t.name`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		QuizID int64  `db:"quiz_id"`
+		Name   string `db:"name"`
+	}
+	if err := h.db.Select(&rows, h.db.Rebind(q), args...); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.QuizID] = append(out[r.QuizID], r.Name)
+	}
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package quiz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/emandor/lemme_service/internal/config"
+)
+
+type listPage struct {
+	Data []struct {
+		ID      int64       `json:"id"`
+		Tags    []string    `json:"tags"`
+		Answers []AnswerRow `json:"answers"`
+	} `json:"data"`
+	Meta ListMeta `json:"meta"`
+}
+
+// listApp serves ListMyQuizzes as userID.
+func listApp(s *Service, userID int64) *fiber.App {
+	h := &Handler{cfg: &config.Config{}, db: s.db, rdb: s.rdb, svc: s, blob: s.blob}
+	app := fiber.New()
+	app.Get("/quizzes", func(c *fiber.Ctx) error {
+		c.Locals("userID", userID)
+		return c.Next()
+	}, h.ListMyQuizzes)
+	return app
+}
+
+func getList(t *testing.T, app *fiber.App, query string) (int, listPage) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/quizzes?"+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var page listPage
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, page
+}
+
+func pageIDs(p listPage) []int64 {
+	ids := []int64{}
+	for _, q := range p.Data {
+		ids = append(ids, q.ID)
+	}
+	return ids
+}
+
+func TestListMyQuizzes(t *testing.T) {
+	s := testService(t)
+	user, other := seedUser(t, s.db), seedUser(t, s.db)
+
+	// q[0] is the oldest
+	var q []int64
+	for i := 0; i < 5; i++ {
+		id := seedQuiz(t, s.db, user, fmt.Sprint("h", i), 1)
+		s.db.MustExec(`UPDATE quizzes SET created_at=? WHERE id=?`, fmt.Sprintf("2025-03-0%d 10:00:00", i+1), id)
+		q = append(q, id)
+	}
+	s.db.MustExec(`UPDATE quizzes SET status='error' WHERE id=?`, q[1])
+	s.db.MustExec(`UPDATE quizzes SET status='processing' WHERE id=?`, q[3])
+	seedQuiz(t, s.db, other, "other", 1)
+	deleted := seedQuiz(t, s.db, user, "deleted", 1)
+	s.db.MustExec(`UPDATE quizzes SET deleted_at=NOW() WHERE id=?`, deleted)
+
+	seedAnswer(t, s.db, q[4], "OPENAI", "B")
+	seedAnswer(t, s.db, q[4], "GEMINI", "ERROR")
+	seedAnswer(t, s.db, q[2], "GEMINI", "C")
+
+	res := s.db.MustExec(`INSERT INTO tags (user_id, name) VALUES (?, 'algebra')`, user)
+	tag, _ := res.LastInsertId()
+	s.db.MustExec(`INSERT INTO quiz_tags (quiz_id, tag_id) VALUES (?, ?), (?, ?)`, q[0], tag, q[4], tag)
+	res = s.db.MustExec(`INSERT INTO folders (user_id, name) VALUES (?, 'week 1')`, user)
+	folder, _ := res.LastInsertId()
+	s.db.MustExec(`INSERT INTO quiz_folders (quiz_id, folder_id) VALUES (?, ?), (?, ?)`, q[1], folder, q[2], folder)
+
+	app := listApp(s, user)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{"all", "", []int64{q[4], q[3], q[2], q[1], q[0]}},
+		{"status", "status=completed", []int64{q[4], q[2], q[0]}},
+		{"status case", "status=ERROR", []int64{q[1]}},
+		{"provider", "provider=gemini", []int64{q[2]}},
+		{"tag", "tag=algebra", []int64{q[4], q[0]}},
+		{"unknown tag", "tag=history", []int64{}},
+		{"folder", fmt.Sprint("folder=", folder), []int64{q[2], q[1]}},
+		{"from date", "from=2025-03-04", []int64{q[4], q[3]}},
+		{"to date inclusive", "to=2025-03-02", []int64{q[1], q[0]}},
+		{"date range", "from=2025-03-02&to=2025-03-03", []int64{q[2], q[1]}},
+		{"rfc3339", "from=2025-03-03T10:00:00Z&to=2025-03-04T09:00:00Z", []int64{q[2]}},
+		{"combined", "status=completed&tag=algebra&from=2025-03-02", []int64{q[4]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, page := getList(t, app, tt.query)
+			if code != 200 {
+				t.Fatalf("status %d", code)
+			}
+			if got := pageIDs(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("batched answers and tags", func(t *testing.T) {
+		_, page := getList(t, app, "")
+		top := page.Data[0]
+		if len(top.Answers) != 2 || !reflect.DeepEqual(top.Tags, []string{"algebra"}) {
+			t.Errorf("quiz %d: answers %+v, tags %v", top.ID, top.Answers, top.Tags)
+		}
+		if last := page.Data[len(page.Data)-1]; len(last.Answers) != 0 || last.Answers == nil {
+			t.Errorf("quiz %d: answers %+v, want empty list", last.ID, last.Answers)
+		}
+	})
+}
+
+func TestListMyQuizzesCursor(t *testing.T) {
+	s := testService(t)
+	user := seedUser(t, s.db)
+	var q []int64
+	for i := 0; i < 5; i++ {
+		q = append(q, seedQuiz(t, s.db, user, fmt.Sprint("h", i), 1))
+	}
+	app := listApp(s, user)
+
+	var got []int64
+	cursor, pages := "", 0
+	for {
+		code, page := getList(t, app, "limit=2&cursor="+cursor)
+		if code != 200 {
+			t.Fatalf("status %d", code)
+		}
+		pages++
+		if page.Meta.Limit != 2 || len(page.Data) > 2 {
+			t.Fatalf("page %d: limit %d, %d quizzes", pages, page.Meta.Limit, len(page.Data))
+		}
+		got = append(got, pageIDs(page)...)
+		if page.Meta.NextCursor == "" {
+			break
+		}
+		cursor = page.Meta.NextCursor
+	}
+	if want := []int64{q[4], q[3], q[2], q[1], q[0]}; !reflect.DeepEqual(got, want) || pages != 3 {
+		t.Errorf("pages %d, ids %v; want 3 pages of %v", pages, got, want)
+	}
+
+	// an exact last page has no next cursor
+	_, page := getList(t, app, "limit=5")
+	if len(page.Data) != 5 || page.Meta.NextCursor != "" {
+		t.Errorf("limit=5: %d quizzes, next %q", len(page.Data), page.Meta.NextCursor)
+	}
+
+	// out-of-range limits fall back to the default
+	_, page = getList(t, app, "limit=1000")
+	if page.Meta.Limit != defaultListLimit {
+		t.Errorf("limit=1000: meta limit %d", page.Meta.Limit)
+	}
+}
+
+func TestListMyQuizzesBadFilter(t *testing.T) {
+	s := testService(t)
+	app := listApp(s, seedUser(t, s.db))
+	for _, query := range []string{
+		"cursor=!!",
+		"cursor=" + encodeCursor(0)[:1],
+		"status=done",
+		"provider=deepseek",
+		"folder=abc",
+		"folder=-1",
+		"from=yesterday",
+		"to=2025-13-01",
+	} {
+		if code, _ := getList(t, app, query); code != 400 {
+			t.Errorf("%s: status %d, want 400", query, code)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, id := range []int64{1, 42, 1 << 40} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil || got != id {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d, %v", id, got, err)
+		}
+	}
+	for _, s := range []string{"", "%%", encodeCursor(0), encodeCursor(-5)} {
+		if _, err := decodeCursor(s); err == nil {
+			t.Errorf("decodeCursor(%q) accepted", s)
+		}
+	}
+}
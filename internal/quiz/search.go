@@ -0,0 +1,179 @@
+package quiz
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxSearchQueryLen = 200
+	snippetWidth      = 160
+)
+
+type SearchHit struct {
+	ID        int64           `db:"id" json:"id"`
+	Status    string          `db:"status" json:"status"`
+	ImagePath string          `db:"image_key" json:"image_path"`
+	CreatedAt string          `db:"created_at" json:"created_at"`
+	Score     float64         `db:"score" json:"score"`
+	OcrText   string          `db:"ocr_text" json:"-"`
+	Snippet   string          `json:"snippet,omitempty"`
+	Answers   []AnswerSnippet `json:"answers,omitempty"`
+}
+
+type AnswerSnippet struct {
+	Source  string `json:"source"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchQuizzes runs a full-text search over the caller's OCR text, answers
+// and reasons. Snippets are HTML-escaped with matches wrapped in <mark>.
+func (h *Handler) SearchQuizzes(c *fiber.Ctx) error {
+	userID := mustUserID(c)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return c.Status(400).SendString("q required")
+	}
+	if len(q) > maxSearchQueryLen {
+		return c.Status(400).SendString("q too long")
+	}
+	limit := c.QueryInt("limit", defaultListLimit)
+	if limit <= 0 || limit > maxListLimit {
+		limit = defaultListLimit
+	}
+
+	var hits []SearchHit
+	if err := h.db.Select(&hits, `
+        SELECT q.id,q.status,q.image_key,q.created_at,COALESCE(q.ocr_text,'') AS ocr_text,
+            MATCH(q.ocr_text) AGAINST (? IN NATURAL LANGUAGE MODE) + COALESCE(a.score,0) AS score
+        FROM quizzes q
+        LEFT JOIN (
+            SELECT an.quiz_id, MAX(MATCH(an.answer_text,an.reason_text) AGAINST (? IN NATURAL LANGUAGE MODE)) AS score
+            FROM answers an
+            JOIN quizzes aq ON aq.id=an.quiz_id
+            WHERE aq.user_id=? AND aq.deleted_at IS NULL AND an.answer_text<>'ERROR'
+                AND MATCH(an.answer_text,an.reason_text) AGAINST (? IN NATURAL LANGUAGE MODE)
+            GROUP BY an.quiz_id
+        ) a ON a.quiz_id=q.id
+        WHERE q.user_id=? AND q.deleted_at IS NULL
+            AND (MATCH(q.ocr_text) AGAINST (? IN NATURAL LANGUAGE MODE) OR a.quiz_id IS NOT NULL)
+        ORDER BY score DESC, q.id DESC
+        LIMIT ?`, q, q, userID, q, userID, q, limit); err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+
+	ids := make([]int64, len(hits))
+	for i := range hits {
+		ids[i] = hits[i].ID
+	}
+	answers, err := h.loadAnswers(ids)
+	if err != nil {
+		return c.Status(500).SendString("db fail")
+	}
+
+	terms := searchTerms(q)
+	for i := range hits {
+		hits[i].ImagePath = h.imageURL(c.Context(), hits[i].ImagePath)
+		hits[i].Snippet = highlight(hits[i].OcrText, terms, snippetWidth)
+		for _, a := range answers[hits[i].ID] {
+			if a.Answer == "ERROR" {
+				continue
+			}
+			if s := highlight(a.Answer+" — "+a.Reason, terms, snippetWidth); strings.Contains(s, "<mark>") {
+				hits[i].Answers = append(hits[i].Answers, AnswerSnippet{Source: a.Source, Snippet: s})
+			}
+		}
+	}
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+
+	return c.JSON(fiber.Map{"data": hits, "meta": ListMeta{Limit: limit}})
+}
+
+// searchTerms splits the query into lower-cased words worth highlighting.
+func searchTerms(q string) []string {
+	var out []string
+	for _, f := range strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(f)) >= 2 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// highlight cuts a window of about width runes around the first term found
+// in text and wraps every term occurrence inside it with <mark>.
+// Without a match it returns the beginning of the text.
+func highlight(text string, terms []string, width int) string {
+	src := []rune(strings.Join(strings.Fields(text), " "))
+	if len(src) == 0 {
+		return ""
+	}
+	lower := make([]rune, len(src))
+	for i, r := range src {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	first := -1
+	for _, t := range terms {
+		if i := runeIndex(lower, []rune(t)); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start := 0
+	if first > width/3 {
+		start = first - width/3
+	}
+	end := min(start+width, len(src))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, t := range terms {
+			tr := []rune(t)
+			if len(tr) > matched && i+len(tr) <= end && runeIndex(lower[i:i+len(tr)], tr) == 0 {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(string(src[i : i+matched])))
+			b.WriteString("</mark>")
+			i += matched
+			continue
+		}
+		b.WriteString(html.EscapeString(string(src[i])))
+		i++
+	}
+	if end < len(src) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		ok := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
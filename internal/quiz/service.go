@@ -3,12 +3,17 @@ package quiz
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"strconv"
 	"strings"
 
 	"time"
 
 	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/lang"
+	"github.com/emandor/lemme_service/internal/segment"
+	"github.com/emandor/lemme_service/internal/storage"
 	"github.com/emandor/lemme_service/internal/telemetry"
 	ws "github.com/emandor/lemme_service/internal/ws"
 
@@ -17,22 +22,34 @@ import (
 
 	"github.com/emandor/lemme_service/internal/ocr"
 	"github.com/emandor/lemme_service/internal/providers"
-
-	"golang.org/x/sync/errgroup"
 )
 
 type Service struct {
 	db      *sqlx.DB
 	rdb     *redis.Client
+	blob    storage.Blob
 	clients []providers.Client
 	ocrLang string
 	ocr     interface {
 		Read(ctx context.Context, img []byte, mime string) (ocr.Result, error)
+		ReadMath(ctx context.Context, img []byte, mime string) (ocr.Result, error)
 	}
+	ocrMode     OCRMode
 	ocrMaxW     int
 	ocrQuality  int
 	ocrGray     bool
+	ocrSteps    []img.Step
 	ocrCacheTTL time.Duration
+	titleSource providers.SourceName
+	// titleModel and titleMaxTokens are passed to Completer.Complete.
+	titleModel     string
+	titleMaxTokens int
+	stream         bool
+	prompts        *promptStore
+	strategy       Strategy
+	inputMode      InputMode
+	firstK         int
+	hedgeDelay     time.Duration
 }
 
 func (s *Service) ProcessAsync(quizID int64, _imagePathIgnored string) {
@@ -52,111 +69,159 @@ func (s *Service) ProcessAsync(quizID int64, _imagePathIgnored string) {
 		}
 		defer s.rdb.Del(ctx, lockKey)
 
-		// get image info from DB (need path + hash)
-		var row struct {
-			ImagePath string `db:"image_path"`
-			Hash      string `db:"image_hash"`
-		}
-		// if not found, mark error and return
-		if err := s.db.Get(&row, `SELECT image_path, image_hash FROM quizzes WHERE id=?`, quizID); err != nil {
-			log.Error().Err(err).Msg("quiz_not_found")
-			s.markError(quizID, err)
-			return
-		}
-
-		// find in redis using hash as key
-		cacheKey := "ocr:" + row.Hash
-		if txt, err := s.rdb.Get(ctx, cacheKey).Result(); err == nil && strings.TrimSpace(txt) != "" {
-			log.Info().Int("len", len(txt)).Msg("ocr_cache_hit")
-			s.saveOCR(quizID, txt)
+		if s.quizInputMode(quizID) == InputVision {
+			// the providers read the images themselves
+			s.skipOCR(quizID)
 		} else {
-			log.Info().Str("img", row.ImagePath).Msg("ocr_cache_miss_preprocess")
-
-			// Preprocess for efficient budget usage
-			prep, err := img.PrepareForOCR(row.ImagePath, s.ocrMaxW, s.ocrQuality, s.ocrGray)
-			if err != nil {
-				log.Error().Err(err).Msg("ocr_prep_fail")
-				s.markError(quizID, err)
-				return
-			}
-
-			// call OCR service with 45s timeout
-			ocrCtx, cancel := context.WithTimeout(ctx, 45*time.Second)
-			defer cancel()
-			res, err := s.ocr.Read(ocrCtx, prep.Bytes, prep.MIME)
+			// OCR every image (cache keyed by image hash), joined in order
+			txt, latex, err := s.readImages(ctx, quizID, s.quizOCRMode(quizID))
 			if err != nil {
 				log.Error().Err(err).Msg("ocr_fail")
 				s.markError(quizID, err)
 				return
 			}
+			s.saveOCR(quizID, txt, latex)
+		}
 
-			txt := strings.TrimSpace(res.Text)
-			log.Info().Int("len", len(txt)).Msg("ocr_done")
-			s.saveOCR(quizID, txt)
+		s.answer(ctx, quizID, true)
+	}()
+}
 
-			if len(txt) > 0 && s.ocrCacheTTL > 0 {
-				if err := s.rdb.Set(ctx, cacheKey, txt, s.ocrCacheTTL).Err(); err != nil {
-					log.Warn().Err(err).Msg("ocr_cache_set_err")
-				}
+// answer sends the quiz OCR text (and, by input mode, its images) to every
+// provider, stores their answers and completes the quiz. With split, text
+// holding several numbered questions is asked as a multi-question prompt
+// answered per question.
+func (s *Service) answer(ctx context.Context, quizID int64, split bool) {
+	log := telemetry.L().With().Int64("quiz_id", quizID).Logger()
+
+	// build prompt from latest OCR text, listing detected choices
+	txt, latex := s.latestOCR(quizID)
+	opts := segment.ExtractOptions(txt)
+	code, subject, answerLang := s.promptContext(quizID)
+	kind := providers.PromptSingle
+	vars := providers.PromptVars{
+		OCR: txt, LaTeX: latex, Choices: optionStrings(opts),
+		Language: code, Subject: subject, AnswerLanguage: answerLang,
+	}
+	partOpts := map[int][]segment.Option{}
+	if split {
+		if _, qs := segment.Split(txt); len(qs) > 1 {
+			numbers := make([]int, len(qs))
+			for i, q := range qs {
+				numbers[i] = q.Number
+				partOpts[q.Number] = segment.ExtractOptions(q.Text)
 			}
+			opts = nil
+			kind = providers.PromptMulti
+			vars.Choices, vars.Numbers = nil, numbers
+			log.Info().Int("questions", len(qs)).Msg("quiz_segmented")
 		}
+	}
+	mode, clients := s.quizInputMode(quizID), s.clients
+	if mode != InputText {
+		images, err := s.promptImages(ctx, quizID)
+		if err == nil && len(imageClients(clients)) == 0 {
+			err = errors.New("no provider accepts images")
+		}
+		switch {
+		case err == nil:
+			ctx = providers.WithImages(ctx, images)
+			vars.Images = len(images)
+			clients = imageClients(clients)
+		case mode == InputHybrid:
+			log.Warn().Err(err).Msg("vision_unavailable_text_only")
+			mode = InputText
+		default:
+			log.Error().Err(err).Msg("vision_fail")
+			s.markError(quizID, err)
+			return
+		}
+	}
 
-		// build prompt from latest OCR text
-		txt := s.latestOCR(quizID)
-		prompt := providers.BuildPrompt(txt)
-		log.Debug().Int("prompt_len", len(prompt)).Msg("prompt_built")
-		// debug prompt message
-		log.Debug().Str("prompt", prompt).Msg("prompt_full")
-
-		// Fan Out to each provider (text models)
-		g, gctx := errgroup.WithContext(ctx)
-		g.SetLimit(min(len(s.clients), 3)) // mis. 3 concurrent;
+	prompt, version := s.renderPrompt(quizID, kind, vars)
+	log.Debug().Int("prompt_len", len(prompt)).Str("prompt_version", version).Str("input_mode", string(mode)).Msg("prompt_built")
+	// debug prompt message
+	log.Debug().Str("prompt", prompt).Msg("prompt_full")
 
-		for _, cl := range s.clients {
-			cli := cl // capture range var
-			g.Go(func() error {
-				// recover so that if 1 provider panics, it doesn't crash the whole process
-				defer func() {
-					if r := recover(); r != nil {
-						log.Error().Str("provider", string(cli.Name())).Interface("panic", r).Msg("provider_panic")
-					}
-				}()
+	// Fan Out to the providers (text models)
+	ctx = providers.WithPromptKind(providers.WithLanguage(ctx, code), kind)
+	s.fanOut(ctx, quizID, s.quizStrategy(quizID), clients, prompt, func(r providerResult) {
+		cli, ans, err := r.cli, r.ans, r.err
+		if err != nil {
+			log.Error().Err(err).Str("provider", string(cli.Name())).Msg("provider_ask_error")
 
-				askCtx, cancel := context.WithTimeout(gctx, 60*time.Second)
-				defer cancel()
+			// save "ERROR" answer but don't fail the whole process
+			s.saveAnswer(quizID, cli.Name(), version, mode, providers.Answer{Answer: "ERROR", Reason: err.Error()}, err)
 
-				ans, err := cli.Ask(askCtx, prompt)
+			ws.BroadcastQuizUpdate(quizID, cli.Name(), nil, err)
+			return
+		}
 
-				if err != nil {
-					log.Error().Err(err).Str("provider", string(cli.Name())).Msg("provider_ask_error")
+		log.Info().Str("provider", string(cli.Name())).Int("len", len(ans.Answer)).Int("latency_ms", ans.LatencyMs).
+			Str("parse_mode", ans.ParseMode).Float64("parse_confidence", ans.ParseConfidence).
+			Strs("parse_warnings", ans.ParseWarnings).Msg("provider_done")
 
-					// save "ERROR" answer but don't fail the whole process
-					s.saveAnswer(quizID, cli.Name(), providers.Answer{Answer: "ERROR", Reason: err.Error()}, err)
+		canonicalizeAnswer(&ans, code, opts, partOpts)
 
-					ws.BroadcastQuizUpdate(quizID, cli.Name(), nil, err)
-					return nil
-				}
+		s.saveAnswer(quizID, cli.Name(), version, mode, ans, nil)
+		ws.BroadcastQuizUpdate(quizID, cli.Name(), &ans, nil)
+	})
 
-				log.Info().Str("provider", string(cli.Name())).Int("len", len(ans.Answer)).Int("latency_ms", ans.LatencyMs).Msg("provider_done")
+	s.markCompleted(quizID)
+	ws.BroadcastQuizCompleted(quizID)
 
-				s.saveAnswer(quizID, cli.Name(), ans, nil)
-				ws.BroadcastQuizUpdate(quizID, cli.Name(), &ans, nil)
-				return nil
-			})
-		}
+	log.Info().Str("stage", "completed").Msg("process_quiz")
+}
 
-		// wait for all providers to finish
-		_ = g.Wait()
+// ask streams the answer to WS subscribers when the provider supports it.
+func (s *Service) ask(ctx context.Context, quizID int64, cli providers.Client, prompt string) (providers.Answer, error) {
+	if st, ok := cli.(providers.Streamer); ok && s.stream && ws.HasSubscribers(quizID) {
+		return st.Stream(ctx, prompt, func(delta string) {
+			ws.BroadcastAnswerDelta(quizID, cli.Name(), delta)
+		})
+	}
+	return cli.Ask(ctx, prompt)
+}
 
-		s.markCompleted(quizID)
-		ws.BroadcastQuizCompleted(quizID)
+// canonicalizeAnswer replaces free-text answers with the label of the
+// option they name and lists the labels in Options. Numeric option texts
+// are written the way the parser wrote the answer in language code
+// ("2,5" → "2.5").
+func canonicalizeAnswer(ans *providers.Answer, code string, opts []segment.Option, partOpts map[int][]segment.Option) {
+	apply := func(a *providers.Answer, opts []segment.Option) {
+		if len(opts) == 0 {
+			return
+		}
+		a.Options = a.Options[:0]
+		texts := make([]segment.Option, len(opts))
+		for i, o := range opts {
+			a.Options = append(a.Options, o.Label)
+			texts[i] = segment.Option{Label: o.Label, Text: providers.NormalizeNumber(o.Text, code)}
+		}
+		if label, ok := segment.MatchOption(a.Answer, texts); ok {
+			a.Answer = label
+		}
+	}
+	apply(ans, opts)
+	for i := range ans.Parts {
+		apply(&ans.Parts[i], partOpts[ans.Parts[i].Number])
+	}
+}
 
-		log.Info().Str("stage", "completed").Msg("process_quiz")
-	}()
+func optionStrings(opts []segment.Option) []string {
+	out := make([]string, len(opts))
+	for i, o := range opts {
+		out[i] = o.String()
+	}
+	return out
 }
 
-func (s *Service) saveOCR(quizID int64, text string) {
-	_, _ = s.db.Exec(`UPDATE quizzes SET ocr_text=?, status='processing', updated_at=NOW() WHERE id=?`, text, quizID)
+// saveOCR stores the OCR text and, in math mode, its LaTeX variant.
+func (s *Service) saveOCR(quizID int64, text, latex string) {
+	// the language is guessed among OCR_LANG; NULL when unsure
+	code := lang.Detect(text, lang.Candidates(s.ocrLang)...)
+	_, _ = s.db.Exec(`UPDATE quizzes SET ocr_text=?, ocr_latex=?, ocr_lang=?, status='processing', updated_at=NOW() WHERE id=?`,
+		text, nullIfEmpty(latex), nullIfEmpty(code), quizID)
 
 	// delay broadcast slightly to ensure websocket client is ready
 	time.Sleep(500 * time.Millisecond)
@@ -164,19 +229,41 @@ func (s *Service) saveOCR(quizID int64, text string) {
 	ws.BroadcastQuizOCRDone(quizID, text)
 }
 
-func (s *Service) saveAnswer(quizID int64, source providers.SourceName, ans providers.Answer, err error) {
+func (s *Service) saveAnswer(quizID int64, source providers.SourceName, promptVersion string, mode InputMode, ans providers.Answer, err error) {
 	if err != nil {
-		_, _ = s.db.Exec(`INSERT INTO answers(quiz_id,source,answer_text,reason_text) VALUES(?,?,?,?)
-			ON DUPLICATE KEY UPDATE reason_text=?`,
-			quizID, source, "ERROR", err.Error(), err.Error())
+		_, _ = s.db.Exec(`INSERT INTO answers(quiz_id,source,answer_text,reason_text,prompt_version,input_mode) VALUES(?,?,?,?,?,?)
+			ON DUPLICATE KEY UPDATE reason_text=?, prompt_version=VALUES(prompt_version), input_mode=VALUES(input_mode)`,
+			quizID, source, "ERROR", err.Error(), promptVersion, mode, err.Error())
 		return
 	}
-	_, _ = s.db.Exec(`INSERT INTO answers(quiz_id,source,answer_text,reason_text,latency_ms,created_at)
-			VALUES(?,?,?,?,?,NOW())
+	// multi-question answers are stored per question, without the summary
+	parts := ans.Parts
+	if len(parts) == 0 {
+		parts = []providers.Answer{ans}
+	}
+	// every part keeps the whole reply and its parse diagnostics
+	var warnings any
+	if len(ans.ParseWarnings) > 0 {
+		b, _ := json.Marshal(ans.ParseWarnings)
+		warnings = string(b)
+	}
+	for _, p := range parts {
+		_, _ = s.db.Exec(`INSERT INTO answers(quiz_id,source,question_no,answer_text,reason_text,latency_ms,prompt_version,
+				parse_mode,parse_confidence,parse_warnings_json,raw_text,reasoning_text,input_mode,created_at)
+			VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,NOW())
 			ON DUPLICATE KEY UPDATE answer_text=VALUES(answer_text),
 				reason_text=VALUES(reason_text),
-				latency_ms=VALUES(latency_ms)`,
-		quizID, source, ans.Answer, ans.Reason, ans.LatencyMs)
+				latency_ms=VALUES(latency_ms),
+				prompt_version=VALUES(prompt_version),
+				parse_mode=VALUES(parse_mode),
+				parse_confidence=VALUES(parse_confidence),
+				parse_warnings_json=VALUES(parse_warnings_json),
+				raw_text=VALUES(raw_text),
+				reasoning_text=VALUES(reasoning_text),
+				input_mode=VALUES(input_mode)`,
+			quizID, source, p.Number, p.Answer, p.Reason, ans.LatencyMs, promptVersion,
+			nullIfEmpty(ans.ParseMode), ans.ParseConfidence, warnings, nullIfEmpty(ans.Raw), nullIfEmpty(ans.Reasoning), mode)
+	}
 }
 
 func (s *Service) markError(quizID int64, _ error) {
@@ -187,14 +274,49 @@ func (s *Service) markCompleted(quizID int64) {
 	_, _ = s.db.Exec(`UPDATE quizzes SET status='completed', updated_at=NOW() WHERE id=?`, quizID)
 }
 
-// latestOCR get ocr_text from DB (safe for prompt builder)
-func (s *Service) latestOCR(quizID int64) string {
-	var txt sql.NullString
-	if err := s.db.Get(&txt, `SELECT ocr_text FROM quizzes WHERE id=?`, quizID); err != nil {
-		return ""
+// GenerateTitle asks one provider (TITLE_PROVIDER, or the first configured)
+// for a short plain-text title of the OCR text, with the cheap TITLE_MODEL.
+func (s *Service) GenerateTitle(ctx context.Context, ocrText string) (string, error) {
+	var cli providers.Client
+	for _, c := range s.clients {
+		if cli == nil || c.Name() == s.titleSource {
+			cli = c
+		}
+	}
+	if cli == nil {
+		return "", errors.New("no provider configured")
+	}
+	comp, ok := cli.(providers.Completer)
+	if !ok {
+		return "", errors.New(string(cli.Name()) + " cannot write titles")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	title, err := comp.Complete(ctx, s.titleModel, providers.BuildTitlePrompt(ocrText), s.titleMaxTokens)
+	if err != nil {
+		return "", err
+	}
+	return cleanTitle(title), nil
+}
+
+// cleanTitle trims a title to one line that fits quizzes.title.
+func cleanTitle(t string) string {
+	t = strings.Join(strings.Fields(strings.Trim(strings.TrimSpace(t), "\"'“”")), " ")
+	if r := []rune(t); len(r) > maxTitleLen {
+		t = string(r[:maxTitleLen])
+	}
+	return t
+}
+
+// latestOCR get ocr_text and ocr_latex from DB (safe for prompt builder)
+func (s *Service) latestOCR(quizID int64) (text, latex string) {
+	var row struct {
+		Text  sql.NullString `db:"ocr_text"`
+		LaTeX sql.NullString `db:"ocr_latex"`
 	}
-	if txt.Valid {
-		return txt.String
+	if err := s.db.Get(&row, `SELECT ocr_text, ocr_latex FROM quizzes WHERE id=?`, quizID); err != nil {
+		return "", ""
 	}
-	return ""
+	return row.Text.String, row.LaTeX.String
 }
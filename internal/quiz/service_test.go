@@ -0,0 +1,30 @@
+package quiz
+
+import (
+	"testing"
+
+	"github.com/emandor/lemme_service/internal/lang"
+	"github.com/emandor/lemme_service/internal/providers"
+	"github.com/emandor/lemme_service/internal/segment"
+)
+
+func TestCanonicalizeAnswer(t *testing.T) {
+	opts := segment.ExtractOptions("Berapa 5/2?\nA. 2,5\nB. 3,5\nC. Paris")
+	tests := []struct {
+		answer, code, want string
+	}{
+		// the parser wrote "2,5" as 2.5 in an Indonesian quiz
+		{"2.5", lang.Indonesian, "A"},
+		{"b", lang.Indonesian, "B"},
+		{"paris", lang.English, "C"},
+		{"2.5", lang.English, "2.5"},
+		{"4", lang.Indonesian, "4"},
+	}
+	for _, tt := range tests {
+		ans := providers.Answer{Answer: tt.answer}
+		canonicalizeAnswer(&ans, tt.code, opts, nil)
+		if ans.Answer != tt.want || len(ans.Options) != 3 {
+			t.Errorf("%q (%s) = %q, options %v; want %q", tt.answer, tt.code, ans.Answer, ans.Options, tt.want)
+		}
+	}
+}
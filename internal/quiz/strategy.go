@@ -0,0 +1,145 @@
+package quiz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/providers"
+	"github.com/emandor/lemme_service/internal/telemetry"
+)
+
+// Strategy decides how a quiz fans out to the providers.
+type Strategy string
+
+const (
+	// StrategyAll asks every provider and waits for all of them.
+	StrategyAll Strategy = "all"
+	// StrategyFirstK asks every provider and cancels the rest after k
+	// successful answers.
+	StrategyFirstK Strategy = "first-k"
+	// StrategyFallback asks the providers one by one until one succeeds.
+	StrategyFallback Strategy = "fallback"
+	// StrategyHedge asks the first provider and adds the next one whenever
+	// the hedge delay passes (or a call fails) without an answer.
+	StrategyHedge Strategy = "hedge"
+)
+
+// parseStrategy validates a configured or requested strategy; empty means
+// "use the default".
+func parseStrategy(v string) (Strategy, error) {
+	switch st := Strategy(v); st {
+	case "", StrategyAll, StrategyFirstK, StrategyFallback, StrategyHedge:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown strategy %q", v)
+}
+
+// providerTimeout bounds one provider call, retries included.
+const providerTimeout = 60 * time.Second
+
+type providerResult struct {
+	cli providers.Client
+	ans providers.Answer
+	err error
+}
+
+// fanOut asks clients according to st and calls handle for every call
+// that finished. Calls cancelled because the strategy was satisfied
+// are dropped: they are neither stored nor counted as provider errors.
+func (s *Service) fanOut(ctx context.Context, quizID int64, st Strategy, clients []providers.Client, prompt string, handle func(providerResult)) {
+	log := telemetry.L().With().Int64("quiz_id", quizID).Str("strategy", string(st)).Logger()
+	if len(clients) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	want := 1
+	switch st {
+	case StrategyAll:
+		want = len(clients)
+	case StrategyFirstK:
+		want = min(max(s.firstK, 1), len(clients))
+	}
+
+	results := make(chan providerResult, len(clients))
+	next, running := 0, 0
+	launch := func() {
+		cli := clients[next]
+		next++
+		running++
+		go func() {
+			r := providerResult{cli: cli}
+			// recover so that if 1 provider panics, it doesn't crash the whole process
+			defer func() {
+				if p := recover(); p != nil {
+					log.Error().Str("provider", string(cli.Name())).Interface("panic", p).Msg("provider_panic")
+					r.err = fmt.Errorf("provider panic: %v", p)
+				}
+				results <- r
+			}()
+			askCtx, cancel := context.WithTimeout(ctx, providerTimeout)
+			defer cancel()
+			r.ans, r.err = s.ask(askCtx, quizID, cli, prompt)
+		}()
+	}
+
+	var hedge <-chan time.Time
+	armHedge := func() {
+		if st == StrategyHedge && next < len(clients) {
+			hedge = time.After(s.hedgeDelay)
+		}
+	}
+
+	switch st {
+	case StrategyAll, StrategyFirstK:
+		for next < len(clients) {
+			launch()
+		}
+	default:
+		launch()
+		armHedge()
+	}
+
+	wins := 0
+	for running > 0 {
+		select {
+		case r := <-results:
+			running--
+			if r.err != nil && ctx.Err() != nil {
+				log.Info().Str("provider", string(r.cli.Name())).Msg("provider_cancelled")
+				continue
+			}
+			handle(r)
+			if r.err == nil {
+				wins++
+			}
+			switch {
+			case wins >= want:
+				cancel()
+			case r.err != nil && (st == StrategyFallback || st == StrategyHedge) && next < len(clients):
+				launch()
+				armHedge()
+			}
+		case <-hedge:
+			hedge = nil
+			if wins < want && next < len(clients) {
+				log.Info().Str("provider", string(clients[next].Name())).Msg("provider_hedged")
+				launch()
+				armHedge()
+			}
+		}
+	}
+}
+
+// quizStrategy is the strategy stored on the quiz, or the configured one.
+func (s *Service) quizStrategy(quizID int64) Strategy {
+	var v string
+	_ = s.db.Get(&v, `SELECT COALESCE(strategy,'') FROM quizzes WHERE id=?`, quizID)
+	if st, err := parseStrategy(v); err == nil && st != "" {
+		return st
+	}
+	return s.strategy
+}
@@ -0,0 +1,156 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/providers"
+)
+
+// fakeClient answers its name after delay, fails with err, or with block
+// waits until its call is cancelled. It records when it was asked and
+// whether the call was cancelled.
+type fakeClient struct {
+	name  providers.SourceName
+	delay time.Duration
+	block bool
+	err   error
+
+	mu        sync.Mutex
+	asked     time.Time
+	calls     int
+	cancelled bool
+}
+
+func (c *fakeClient) Name() providers.SourceName { return c.name }
+
+func (c *fakeClient) Ask(ctx context.Context, _ string) (providers.Answer, error) {
+	c.mu.Lock()
+	c.asked, c.calls = time.Now(), c.calls+1
+	c.mu.Unlock()
+
+	wait := time.After(c.delay)
+	if c.block {
+		wait = nil
+	}
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.cancelled = true
+		c.mu.Unlock()
+		return providers.Answer{}, ctx.Err()
+	}
+	if c.err != nil {
+		return providers.Answer{}, c.err
+	}
+	return providers.Answer{Answer: string(c.name)}, nil
+}
+
+func (c *fakeClient) state() (calls int, asked time.Time, cancelled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls, c.asked, c.cancelled
+}
+
+// runFanOut returns the providers handle saw, in order, with their errors.
+func runFanOut(s *Service, st Strategy, clients ...providers.Client) (names []providers.SourceName, errs []error) {
+	s.fanOut(context.Background(), 1, st, clients, "prompt", func(r providerResult) {
+		names = append(names, r.cli.Name())
+		errs = append(errs, r.err)
+	})
+	return names, errs
+}
+
+func TestFanOutAll(t *testing.T) {
+	a := &fakeClient{name: "A", delay: 10 * time.Millisecond}
+	b := &fakeClient{name: "B", err: errors.New("down")}
+	c := &fakeClient{name: "C"}
+	names, errs := runFanOut(&Service{}, StrategyAll, a, b, c)
+	if len(names) != 3 {
+		t.Fatalf("handled %v", names)
+	}
+	for i, n := range names {
+		if (n == "B") != (errs[i] != nil) {
+			t.Errorf("%s: err %v", n, errs[i])
+		}
+	}
+}
+
+func TestFanOutFirstK(t *testing.T) {
+	a := &fakeClient{name: "A"}
+	b := &fakeClient{name: "B", delay: 10 * time.Millisecond}
+	slow := &fakeClient{name: "SLOW", block: true}
+	failing := &fakeClient{name: "FAIL", err: errors.New("down")}
+
+	names, errs := runFanOut(&Service{firstK: 2}, StrategyFirstK, a, slow, failing, b)
+	wins := 0
+	for i, n := range names {
+		if n == "SLOW" {
+			t.Error("cancelled call handled")
+		}
+		if errs[i] == nil {
+			wins++
+		}
+	}
+	if wins != 2 {
+		t.Errorf("handled %v (%v), want 2 answers", names, errs)
+	}
+	if _, _, cancelled := slow.state(); !cancelled {
+		t.Error("the call still running after k answers was not cancelled")
+	}
+}
+
+func TestFanOutFallback(t *testing.T) {
+	a := &fakeClient{name: "A", err: errors.New("down")}
+	b := &fakeClient{name: "B", delay: 5 * time.Millisecond}
+	c := &fakeClient{name: "C"}
+
+	names, errs := runFanOut(&Service{}, StrategyFallback, a, b, c)
+	if len(names) != 2 || names[0] != "A" || errs[0] == nil || names[1] != "B" || errs[1] != nil {
+		t.Fatalf("handled %v (%v), want A failing then B", names, errs)
+	}
+	_, failed, _ := a.state()
+	_, asked, _ := b.state()
+	if asked.Before(failed) {
+		t.Error("B asked before A failed")
+	}
+	if calls, _, _ := c.state(); calls != 0 {
+		t.Error("C asked after B answered")
+	}
+}
+
+func TestFanOutHedge(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	slow := &fakeClient{name: "SLOW", block: true}
+	fast := &fakeClient{name: "FAST", delay: 5 * time.Millisecond}
+	spare := &fakeClient{name: "SPARE"}
+
+	names, _ := runFanOut(&Service{hedgeDelay: delay}, StrategyHedge, slow, fast, spare)
+	if len(names) != 1 || names[0] != "FAST" {
+		t.Fatalf("handled %v, want FAST only", names)
+	}
+	_, first, cancelled := slow.state()
+	_, hedged, _ := fast.state()
+	if d := hedged.Sub(first); d < delay {
+		t.Errorf("second provider launched after %v, want the hedge delay of %v", d, delay)
+	}
+	if !cancelled {
+		t.Error("the slow call was not cancelled after the hedged answer")
+	}
+	if calls, _, _ := spare.state(); calls != 0 {
+		t.Error("third provider asked after an answer")
+	}
+
+	// a failure launches the next provider without waiting for the delay
+	failing := &fakeClient{name: "FAIL", err: errors.New("down")}
+	next := &fakeClient{name: "NEXT"}
+	t0 := time.Now()
+	names, _ = runFanOut(&Service{hedgeDelay: time.Hour}, StrategyHedge, failing, next)
+	if len(names) != 2 || names[1] != "NEXT" || time.Since(t0) > time.Second {
+		t.Errorf("handled %v after %v", names, time.Since(t0))
+	}
+}
@@ -0,0 +1,84 @@
+package quiz
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/emandor/lemme_service/internal/db"
+	"github.com/emandor/lemme_service/internal/storage"
+)
+
+// testTables are emptied before every DB-backed test, children first.
+var testTables = []string{
+	"answers", "providers_logs", "quiz_images", "quiz_tags", "quiz_folders",
+	"tags", "folders", "quizzes", "quiz_batches", "user_sessions", "users",
+}
+
+// testService returns a Service on the MySQL database of TEST_DB_DSN
+// (e.g. "root@tcp(127.0.0.1:3306)/lemme_test?multiStatements=true"),
+// migrated and emptied, with an in-memory redis and blob store. Tests that
+// need MySQL are skipped without it.
+func testService(t *testing.T) *Service {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	d := db.MustConnect(dsn)
+	t.Cleanup(func() { d.Close() })
+	db.MustMigrate(d)
+	for _, table := range testTables {
+		d.MustExec("DELETE FROM " + table)
+	}
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	blob := storage.NewMemory(storage.NewSigner("test", "http://localhost"))
+	return &Service{db: d, rdb: rdb, blob: blob}
+}
+
+// seedUser inserts a user and returns its id.
+func seedUser(t *testing.T, d *sqlx.DB) int64 {
+	t.Helper()
+	var n int
+	_ = d.Get(&n, `SELECT COUNT(*) FROM users`)
+	res := d.MustExec(`INSERT INTO users (provider, provider_id, email) VALUES ('google', ?, ?)`,
+		fmt.Sprint("sub-", n), fmt.Sprintf("user%d@example.com", n))
+	id, _ := res.LastInsertId()
+	return id
+}
+
+// seedQuiz inserts a completed quiz of userID made of images screenshots,
+// the first one hashed hash, and returns its id. Tests adjust the other
+// columns with UPDATEs.
+func seedQuiz(t *testing.T, d *sqlx.DB, userID int64, hash string, images int) int64 {
+	t.Helper()
+	res := d.MustExec(`
+        INSERT INTO quizzes (user_id, image_key, image_hash, status, created_at, updated_at)
+        VALUES (?, ?, ?, 'completed', NOW(), NOW())`,
+		userID, "quizzes/"+hash+".jpg", hash)
+	id, _ := res.LastInsertId()
+	for i := 0; i < images; i++ {
+		h := hash
+		if i > 0 {
+			h = fmt.Sprintf("%s-%d", hash, i)
+		}
+		d.MustExec(`
+            INSERT INTO quiz_images (quiz_id, position, image_key, image_hash, ocr_status)
+            VALUES (?, ?, ?, ?, 'done')`, id, i, "quizzes/"+h+".jpg", h)
+	}
+	return id
+}
+
+// seedAnswer stores an answer of source for quizID.
+func seedAnswer(t *testing.T, d *sqlx.DB, quizID int64, source, text string) {
+	t.Helper()
+	d.MustExec(`INSERT INTO answers (quiz_id, source, answer_text) VALUES (?, ?, ?)`, quizID, source, text)
+}
@@ -0,0 +1,59 @@
+package quiz
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/emandor/lemme_service/internal/providers"
+)
+
+// titleClient records the Complete calls made to it.
+type titleClient struct {
+	name  providers.SourceName
+	reply string
+	calls []string
+}
+
+func (c *titleClient) Name() providers.SourceName { return c.name }
+
+func (c *titleClient) Ask(context.Context, string) (providers.Answer, error) {
+	panic("titles must not use Ask")
+}
+
+func (c *titleClient) Complete(_ context.Context, model, prompt string, maxTokens int) (string, error) {
+	c.calls = append(c.calls, model)
+	if !strings.Contains(prompt, "2+2") || maxTokens != 24 {
+		return "", context.Canceled
+	}
+	return c.reply, nil
+}
+
+func TestGenerateTitle(t *testing.T) {
+	openai := &titleClient{name: providers.SourceOpenAI, reply: "x"}
+	gemini := &titleClient{name: providers.SourceGemini, reply: " \"Simple  addition\"\n"}
+	s := &Service{
+		clients:        []providers.Client{openai, gemini},
+		titleSource:    providers.SourceGemini,
+		titleModel:     "gemini-flash",
+		titleMaxTokens: 24,
+	}
+
+	title, err := s.GenerateTitle(context.Background(), "1. What is 2+2?")
+	if err != nil || title != "Simple addition" {
+		t.Fatalf("GenerateTitle = %q, %v", title, err)
+	}
+	if len(openai.calls) != 0 || len(gemini.calls) != 1 || gemini.calls[0] != "gemini-flash" {
+		t.Errorf("calls: openai %v, gemini %v", openai.calls, gemini.calls)
+	}
+
+	// without TITLE_PROVIDER the first client writes it
+	s.titleSource = ""
+	if _, err := s.GenerateTitle(context.Background(), "What is 2+2?"); err != nil || len(openai.calls) != 1 {
+		t.Errorf("first client: %v, calls %v", err, openai.calls)
+	}
+
+	if _, err := (&Service{}).GenerateTitle(context.Background(), "x"); err == nil {
+		t.Error("title generated without providers")
+	}
+}
@@ -0,0 +1,113 @@
+package quiz
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/emandor/lemme_service/internal/config"
+	"github.com/emandor/lemme_service/internal/img"
+	"github.com/emandor/lemme_service/internal/middleware"
+	"github.com/emandor/lemme_service/internal/storage"
+)
+
+// sampleImage reads a file of the img package's format corpus.
+func sampleImage(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("..", "img", "testdata", "formats", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// postImages uploads files as the "images" of a new quiz of userID.
+func postImages(t *testing.T, h *Handler, userID int64, files ...string) (int, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range files {
+		fw, err := mw.CreateFormFile("images", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(sampleImage(t, name))
+	}
+	mw.Close()
+
+	app := fiber.New()
+	app.Post("/quizzes", func(c *fiber.Ctx) error {
+		c.Locals(middleware.ReqIDKey, "test")
+		c.Locals("userID", userID)
+		return c.Next()
+	}, h.CreateQuiz)
+	req := httptest.NewRequest("POST", "/quizzes", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(msg)
+}
+
+func TestCreateQuizRejectsUndecodableImages(t *testing.T) {
+	s := testService(t)
+	user := seedUser(t, s.db)
+	blob := &keyedBlob{Memory: storage.NewMemory(storage.NewSigner("test", "http://localhost")), keys: map[string]bool{}}
+	s.blob = blob
+	h := &Handler{cfg: &config.Config{QuizMaxImages: 5}, db: s.db, rdb: s.rdb, svc: s, blob: blob}
+
+	prev := img.HEIFDecoderCmd
+	img.HEIFDecoderCmd = ""
+	t.Cleanup(func() { img.HEIFDecoderCmd = prev })
+
+	tests := []struct {
+		name  string
+		files []string
+		code  int
+	}{
+		{"not an image", []string{"notimage.txt"}, 415},
+		{"heic without decoder", []string{"sample.heic"}, 415},
+		{"truncated jpeg", []string{"corrupt.jpg"}, 400},
+		{"bad second image", []string{"sample.png", "corrupt.jpg"}, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, msg := postImages(t, h, user, tt.files...); code != tt.code {
+				t.Errorf("status %d (%s), want %d", code, msg, tt.code)
+			}
+			var n int
+			if err := s.db.Get(&n, `SELECT COUNT(*) FROM quizzes`); err != nil || n != 0 {
+				t.Errorf("%d quizzes created (%v)", n, err)
+			}
+		})
+	}
+	if len(blob.keys) != 0 {
+		t.Errorf("stored objects left behind: %v", blob.keys)
+	}
+}
+
+// keyedBlob tracks the keys stored in it.
+type keyedBlob struct {
+	*storage.Memory
+	keys map[string]bool
+}
+
+func (b *keyedBlob) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	b.keys[key] = true
+	return b.Memory.Put(ctx, key, data, contentType)
+}
+
+func (b *keyedBlob) Delete(ctx context.Context, key string) error {
+	delete(b.keys, key)
+	return b.Memory.Delete(ctx, key)
+}
@@ -16,8 +16,21 @@ type UserQuota struct {
 	QuizUsed  int
 }
 
+// Cost is what a quiz charges against QuizQuota. Results reused from an
+// earlier quiz call no provider and are free.
+func Cost(cached bool) int {
+	if cached {
+		return 0
+	}
+	return 1
+}
+
 func (u *UserQuota) CanCreateQuiz() bool {
-	return u.QuizUsed < u.QuizQuota
+	return u.CanAfford(Cost(false))
+}
+
+func (u *UserQuota) CanAfford(cost int) bool {
+	return u.QuizUsed+cost <= u.QuizQuota
 }
 
 func (u *UserQuota) IncrementUsed() error {
@@ -0,0 +1,118 @@
+package segment
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Option is one choice of a multiple-choice question.
+type Option struct {
+	Label string // canonical label: "A".."E" or "1".."5"
+	Text  string
+}
+
+// String renders the option the way prompts list it ("A. 42").
+func (o Option) String() string {
+	if o.Text == "" {
+		return o.Label
+	}
+	return o.Label + ". " + o.Text
+}
+
+// Option labels start a line or follow a wide gap on the same line, which
+// is how OCR renders choices laid out in columns ("A. 3    B. 4").
+var (
+	letterOption  = regexp.MustCompile(`(?m)(?:^|[ \t]{2,}|\t)[ \t]*\(?([A-Ea-e])[.)][ \t]+`)
+	numericOption = regexp.MustCompile(`(?m)(?:^|[ \t]{2,}|\t)[ \t]*\(?([1-5])\)[ \t]+`)
+)
+
+// ExtractOptions finds the choice list of a question: A–E / a)–e) labels,
+// or (1)–(5) / 1)–5) when there are no letters. Labels must run in order
+// from the first one; fewer than two options means none.
+func ExtractOptions(text string) []Option {
+	if opts := scanOptions(text, letterOption, 'A'); len(opts) >= 2 {
+		return opts
+	}
+	if opts := scanOptions(text, numericOption, '1'); len(opts) >= 2 {
+		return opts
+	}
+	return nil
+}
+
+func scanOptions(text string, rx *regexp.Regexp, first byte) []Option {
+	var (
+		opts  []Option
+		ends  []int
+		begin []int
+	)
+	for _, m := range rx.FindAllStringSubmatchIndex(text, -1) {
+		label := strings.ToUpper(text[m[2]:m[3]])
+		if label[0] != first+byte(len(opts)) {
+			// a new run starting over wins over a broken one
+			if label[0] != first {
+				continue
+			}
+			opts, ends, begin = opts[:0], ends[:0], begin[:0]
+		}
+		opts = append(opts, Option{Label: label})
+		begin = append(begin, m[0])
+		ends = append(ends, m[1])
+	}
+	for i := range opts {
+		stop := len(text)
+		if i+1 < len(opts) {
+			stop = begin[i+1]
+		}
+		line, _, _ := strings.Cut(text[ends[i]:stop], "\n")
+		opts[i].Text = strings.TrimSpace(line)
+	}
+	return opts
+}
+
+// MatchOption maps a provider answer onto the label of one of opts: the
+// option text itself, a bare or decorated label ("b", "(B)", "B. 4"), or
+// the single option text contained in the answer. A label followed by a
+// word ("A prime number", "A and C") is prose, not a label.
+func MatchOption(answer string, opts []Option) (string, bool) {
+	a := strings.TrimSpace(answer)
+	if a == "" || len(opts) == 0 {
+		return "", false
+	}
+
+	// the option text exactly, before any label reading of it
+	na := foldText(a)
+	for _, o := range opts {
+		if no := foldText(o.Text); no != "" && no == na {
+			return o.Label, true
+		}
+	}
+
+	// "B", "b)", "(B)", "B. 4", "B: because..."
+	t := strings.TrimLeft(a, "(")
+	for _, o := range opts {
+		if !strings.HasPrefix(strings.ToUpper(t), o.Label) {
+			continue
+		}
+		rest := t[len(o.Label):]
+		if rest == "" || strings.ContainsRune(".):", rune(rest[0])) {
+			return o.Label, true
+		}
+	}
+
+	// the single option contained in the answer; very short texts ("2",
+	// "ya") only match exactly
+	match := ""
+	for _, o := range opts {
+		if no := foldText(o.Text); len(no) >= 3 && strings.Contains(na, no) {
+			if match != "" {
+				return "", false
+			}
+			match = o.Label
+		}
+	}
+	return match, match != ""
+}
+
+func foldText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.Trim(s, ` ."'`))), " ")
+}
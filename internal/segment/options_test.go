@@ -0,0 +1,88 @@
+package segment
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Option
+	}{
+		{
+			name: "letters on their own lines",
+			text: "Which is prime?\nA. 4\nB. 7\nC. 9",
+			want: []Option{{"A", "4"}, {"B", "7"}, {"C", "9"}},
+		},
+		{
+			name: "lowercase parenthesised",
+			text: "Ibu kota Prancis?\n(a) Paris\n(b) Lyon",
+			want: []Option{{"A", "Paris"}, {"B", "Lyon"}},
+		},
+		{
+			name: "columns",
+			text: "2+2 =\nA. 3    B. 4\nC. 5    D. 6",
+			want: []Option{{"A", "3"}, {"B", "4"}, {"C", "5"}, {"D", "6"}},
+		},
+		{
+			name: "numbers without letters",
+			text: "Pick one\n1) red\n2) green\n3) blue",
+			want: []Option{{"1", "red"}, {"2", "green"}, {"3", "blue"}},
+		},
+		{
+			name: "a new run replaces a broken one",
+			text: "A. stray\nC. skipped\nQuestion?\nA. yes\nB. no",
+			want: []Option{{"A", "yes"}, {"B", "no"}},
+		},
+		{name: "a single option is none", text: "A. only one"},
+		{name: "a label inside a sentence", text: "Vitamin A. is fat soluble. B. also"},
+		{name: "no options", text: "What is 2+2?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractOptions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractOptions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchOption(t *testing.T) {
+	letters := []Option{{"A", "Paris"}, {"B", "A prime number"}, {"C", "Lyon and Nice"}, {"D", "2"}}
+	numbers := []Option{{"1", "3"}, {"2", "5"}, {"3", "1"}}
+	tests := []struct {
+		answer string
+		opts   []Option
+		want   string
+	}{
+		{"B", letters, "B"},
+		{"b", letters, "B"},
+		{"(C)", letters, "C"},
+		{"c)", letters, "C"},
+		{"D. 2", letters, "D"},
+		{"A: the capital", letters, "A"},
+		{"paris", letters, "A"},
+		{" Paris. ", letters, "A"},
+		{"A prime number", letters, "B"},
+		{"It is a prime number, 7", letters, "B"},
+		{"2", letters, "D"},
+		{"3", numbers, "1"},
+		{"(2)", numbers, "2"},
+		{"3)", numbers, "3"},
+		{"A and C", letters, ""},
+		{"A or B", letters, ""},
+		{"Paris or a prime number", letters, ""},
+		{"Madrid", letters, ""},
+		{"E", letters, ""},
+		{"", letters, ""},
+		{"A", nil, ""},
+	}
+	for _, tt := range tests {
+		got, ok := MatchOption(tt.answer, tt.opts)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("MatchOption(%q) = %q, %v, want %q", tt.answer, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package segment splits OCR text of a worksheet into individual questions.
+package segment
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Question is one numbered question of a worksheet.
+type Question struct {
+	Number int
+	Text   string
+	// Offset is the byte offset of the question in the segmented text.
+	Offset int
+}
+
+// questionStart matches a line opening a numbered question: "1.", "2)",
+// "No. 3", "Soal 4" or "Question 5". Lettered lines ("a)", "B.") are
+// answer options and never start a question.
+var questionStart = regexp.MustCompile(`(?im)^[ \t]*(?:(?:soal|no\.?|nomor|question|q)[ \t]*(\d{1,3})[ \t]*[.):]?|(\d{1,3})[ \t]*([.)]))[ \t]+\S`)
+
+// maxNumericOptions is the last label of numeric options ("5)"), as read by
+// ExtractOptions.
+const maxNumericOptions = 5
+
+// Split returns the questions of text in order. Numbers must increase by
+// one from the first match and keep the form of its label ("1." or "1)"),
+// which keeps numbered lists inside a question (steps, statements, options)
+// from splitting it. Text before the first question is returned as
+// preamble. Without any numbered question the whole text is a single
+// question; so is a stem followed by one-line "1)"–"5)" options.
+func Split(text string) (preamble string, qs []Question) {
+	type start struct {
+		num, off int
+		form     string
+	}
+	var starts []start
+	for _, m := range questionStart.FindAllStringSubmatchIndex(text, -1) {
+		numIdx, form := 2, "keyword"
+		if m[2] < 0 {
+			numIdx, form = 4, text[m[6]:m[7]]
+		}
+		n, _ := strconv.Atoi(text[m[numIdx]:m[numIdx+1]])
+		if len(starts) == 0 {
+			if n != 1 {
+				continue
+			}
+		} else if prev := starts[len(starts)-1]; n != prev.num+1 || form != prev.form {
+			continue
+		}
+		starts = append(starts, start{num: n, off: m[0], form: form})
+	}
+
+	whole := func() (string, []Question) {
+		if t := strings.TrimSpace(text); t != "" {
+			return "", []Question{{Number: 1, Text: t}}
+		}
+		return "", nil
+	}
+	if len(starts) == 0 {
+		return whole()
+	}
+
+	preamble = strings.TrimSpace(text[:starts[0].off])
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1].off
+		}
+		qs = append(qs, Question{Number: s.num, Text: strings.TrimSpace(text[s.off:end]), Offset: s.off})
+	}
+	if preamble != "" && starts[0].form == ")" && isOptionRun(qs) {
+		return whole()
+	}
+	return preamble, qs
+}
+
+// isOptionRun reports whether "1)"-numbered questions following a stem are
+// rather its options: at most five of them, each on a single line.
+func isOptionRun(qs []Question) bool {
+	if len(qs) > maxNumericOptions {
+		return false
+	}
+	for _, q := range qs {
+		if strings.Contains(q.Text, "\n") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package segment
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		preamble string
+		numbers  []int
+	}{
+		{
+			name:    "numbered questions",
+			text:    "1. What is 2+2?\n2. What is 3+3?\n3. What is 4+4?",
+			numbers: []int{1, 2, 3},
+		},
+		{
+			name:     "preamble",
+			text:     "Answer all questions.\n1) Capital of France?\n   Paris or Lyon\n2) Capital of Spain?\n   Madrid or Seville",
+			preamble: "Answer all questions.",
+			numbers:  []int{1, 2},
+		},
+		{
+			name:    "keyword labels",
+			text:    "Soal 1 Berapa 2+2?\nSoal 2 Berapa 3+3?",
+			numbers: []int{1, 2},
+		},
+		{
+			name:    "numbered options inside numbered questions",
+			text:    "1. Which is prime?\n1) 4\n2) 6\n3) 7\n2. Which is even?\n1) 3\n2) 8",
+			numbers: []int{1, 2},
+		},
+		{
+			name:    "numbered options of a single question",
+			text:    "1. Which is prime?\n1) 4\n2) 6\n3) 7",
+			numbers: []int{1},
+		},
+		{
+			name:    "unnumbered question with numbered options",
+			text:    "Which number is prime?\n1) 4\n2) 6\n3) 7\n4) 8\n5) 9",
+			numbers: []int{1},
+		},
+		{
+			name:    "steps inside a question",
+			text:    "1. Solve:\n1. add\n3. divide\n2. Next question",
+			numbers: []int{1, 2},
+		},
+		{
+			name:    "no numbers",
+			text:    "Which number is prime? 4, 6 or 7",
+			numbers: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preamble, qs := Split(tt.text)
+			if preamble != tt.preamble {
+				t.Errorf("preamble = %q, want %q", preamble, tt.preamble)
+			}
+			var numbers []int
+			for _, q := range qs {
+				numbers = append(numbers, q.Number)
+			}
+			if !reflect.DeepEqual(numbers, tt.numbers) {
+				t.Errorf("numbers = %v, want %v", numbers, tt.numbers)
+			}
+		})
+	}
+}
+
+func TestSplitKeepsOptionsInQuestion(t *testing.T) {
+	text := "1. Which is prime?\n1) 4\n2) 6\n3) 7"
+	_, qs := Split(text)
+	if len(qs) != 1 || qs[0].Text != text {
+		t.Fatalf("Split = %+v", qs)
+	}
+	opts := ExtractOptions(qs[0].Text)
+	want := []Option{{"1", "4"}, {"2", "6"}, {"3", "7"}}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("ExtractOptions = %+v, want %+v", opts, want)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/emandor/lemme_service/internal/config"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Blob stores quiz images by object key, e.g. "quizzes/<uuid>.jpg".
+type Blob interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that grants read access to key until ttl passes.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// MustOpen builds the backend selected by STORAGE_DRIVER (local, s3, memory).
+func MustOpen(cfg *config.Config) Blob {
+	signer := NewSigner(cfg.SessionCookieSecret, cfg.BaseURL)
+	switch cfg.StorageDriver {
+	case "", "local":
+		return &Local{Root: cfg.StorageLocalDir, Signer: signer}
+	case "memory":
+		return NewMemory(signer)
+	case "s3":
+		if cfg.S3Bucket == "" || cfg.S3Endpoint == "" {
+			log.Fatal("storage: S3_ENDPOINT and S3_BUCKET are required")
+		}
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PathStyle)
+	default:
+		log.Fatalf("storage: unknown driver %q", cfg.StorageDriver)
+		return nil
+	}
+}
+
+// contentTypeOf guesses the MIME type of key from its extension.
+func contentTypeOf(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBlob runs the behaviour every backend shares.
+func testBlob(t *testing.T, b Blob) {
+	ctx := context.Background()
+	data := []byte("jpeg bytes")
+	if err := b.Put(ctx, "quizzes/a/1.jpg", data, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 'J' // the stored object is a copy
+
+	for _, key := range []string{"quizzes/a/1.jpg", "storage/quizzes/a/1.jpg", "./quizzes/a/1.jpg"} {
+		got, err := b.Get(ctx, key)
+		if err != nil || string(got) != "jpeg bytes" {
+			t.Errorf("Get(%q) = %q, %v", key, got, err)
+		}
+	}
+	if _, err := b.Get(ctx, "quizzes/a/2.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key: %v", err)
+	}
+
+	if err := b.Put(ctx, "quizzes/a/1.jpg", []byte("v2"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get(ctx, "quizzes/a/1.jpg"); string(got) != "v2" {
+		t.Errorf("overwritten object reads %q", got)
+	}
+
+	if err := b.Delete(ctx, "quizzes/a/1.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get(ctx, "quizzes/a/1.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if err := b.Delete(ctx, "quizzes/a/1.jpg"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBlob(t, NewMemory(NewSigner("secret", "http://localhost")))
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	l := &Local{Root: root, Signer: NewSigner("secret", "http://localhost")}
+	testBlob(t, l)
+
+	ctx := context.Background()
+	if err := l.Put(ctx, "quizzes/b.png", []byte("png"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "quizzes", "b.png")); err != nil || !bytes.Equal(b, []byte("png")) {
+		t.Errorf("file holds %q, %v", b, err)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(root, "quizzes", "*.part")); len(parts) != 0 {
+		t.Errorf("temporary files left: %v", parts)
+	}
+
+	for _, key := range []string{"", ".", "/"} {
+		if err := l.Put(ctx, key, []byte("x"), ""); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+		if _, err := l.Get(ctx, key); err == nil {
+			t.Errorf("Get(%q) accepted", key)
+		}
+	}
+	// keys climbing out of the root are kept below it
+	for _, key := range []string{"../outside.jpg", "quizzes/../../outside.jpg"} {
+		if err := l.Put(ctx, key, []byte("x"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "outside.jpg")); err == nil {
+		t.Error("an object was written outside the root")
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.jpg")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := map[string]string{
+		"quizzes/x.jpg":           "quizzes/x.jpg",
+		"/quizzes/x.jpg":          "quizzes/x.jpg",
+		"storage/quizzes/x.jpg":   "quizzes/x.jpg",
+		"./storage/quizzes/x.jpg": "quizzes/x.jpg",
+		"quizzes//a/./x.jpg":      "quizzes/a/x.jpg",
+		"quizzes/a/../x.jpg":      "quizzes/x.jpg",
+		"../x.jpg":                "x.jpg",
+		"quizzes/..x.jpg":         "",
+		"":                        "",
+		"/":                       "",
+	}
+	for in, want := range tests {
+		if got := cleanKey(in); got != want {
+			t.Errorf("cleanKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
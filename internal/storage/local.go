@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local keeps objects on the local filesystem below Root and hands out URLs
+// signed by Signer, served by Serve.
+type Local struct {
+	Root   string
+	Signer *Signer
+}
+
+func (l *Local) path(key string) (string, error) {
+	k := cleanKey(key)
+	if k == "" {
+		return "", errors.New("storage: invalid key")
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(k)), nil
+}
+
+func (l *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// write then rename so readers never see a partial file
+	tmp := p + ".part"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (l *Local) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	return l.Signer.URL(key, ttl), nil
+}
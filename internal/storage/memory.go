@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process backend for local development and tests.
+type Memory struct {
+	Signer *Signer
+
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemory(signer *Signer) *Memory {
+	return &Memory{Signer: signer, objects: map[string][]byte{}}
+}
+
+func (m *Memory) Put(_ context.Context, key string, data []byte, _ string) error {
+	b := make([]byte, len(data))
+	copy(b, data)
+	m.mu.Lock()
+	m.objects[cleanKey(key)] = b
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	b, ok := m.objects[cleanKey(key)]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.objects, cleanKey(key))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	return m.Signer.URL(key, ttl), nil
+}
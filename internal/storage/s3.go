@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3UnsignedBody  = "UNSIGNED-PAYLOAD"
+	s3AmzDateFormat = "20060102T150405Z"
+	s3MaxPresignTTL = 7 * 24 * time.Hour
+)
+
+// S3 talks to any S3-compatible service (AWS, MinIO, R2, ...) using
+// Signature Version 4. Signed URLs are S3 presigned GET URLs.
+type S3 struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as endpoint/bucket/key (MinIO) instead of
+	// bucket.endpoint/key.
+	PathStyle bool
+	Client    *http.Client
+
+	now func() time.Time
+}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) *S3 {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		panic("storage: invalid S3 endpoint " + endpoint)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Endpoint:  u,
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: pathStyle,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+		now:       time.Now,
+	}
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if contentType == "" {
+		contentType = contentTypeOf(key)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, s3Error("get", resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", resp)
+	}
+	return nil
+}
+
+// SignedURL returns a presigned GET URL. S3 caps presigned URLs at 7 days.
+func (s *S3) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	k := cleanKey(key)
+	if k == "" {
+		return "", errors.New("storage: invalid key")
+	}
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	ttl = min(ttl, s3MaxPresignTTL)
+
+	t := s.now().UTC()
+	u := s.objectURL(k)
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", s3Algorithm)
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(t))
+	q.Set("X-Amz-Date", t.Format(s3AmzDateFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(q)
+
+	creq := strings.Join([]string{
+		http.MethodGet,
+		uriEncode(u.Path, false),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedBody,
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(t, creq)
+	return u.String(), nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	k := cleanKey(key)
+	if k == "" {
+		return nil, errors.New("storage: invalid key")
+	}
+	u := s.objectURL(k)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for h, v := range headers {
+		req.Header.Set(h, v)
+	}
+	s.signRequest(req, body)
+	return s.Client.Do(req)
+}
+
+// signRequest adds SigV4 Authorization headers covering host, the
+// x-amz-* headers and Content-Type.
+func (s *S3) signRequest(req *http.Request, body []byte) {
+	t := s.now().UTC()
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Date", t.Format(s3AmzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	signed := map[string]string{"host": req.URL.Host}
+	for h, v := range req.Header {
+		lh := strings.ToLower(h)
+		if strings.HasPrefix(lh, "x-amz-") || lh == "content-type" {
+			signed[lh] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for h := range signed {
+		names = append(names, h)
+	}
+	sort.Strings(names)
+	var ch strings.Builder
+	for _, h := range names {
+		ch.WriteString(h + ":" + signed[h] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	creq := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		ch.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	req.Header.Set("Authorization", s3Algorithm+
+		" Credential="+s.AccessKey+"/"+s.scope(t)+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+s.signature(t, creq))
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.Endpoint
+	if s.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/" + s3Service + "/aws4_request"
+}
+
+func (s *S3) signature(t time.Time, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	toSign := s3Algorithm + "\n" + t.Format(s3AmzDateFormat) + "\n" + s.scope(t) + "\n" + hex.EncodeToString(sum[:])
+
+	k := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	k = hmacSHA256(k, s.Region)
+	k = hmacSHA256(k, s3Service)
+	k = hmacSHA256(k, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(k, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery sorts and strictly encodes query parameters as SigV4 wants.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters,
+// and '/' unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func s3Error(op string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return errors.New("storage: s3 " + op + " " + resp.Status + ": " + strings.TrimSpace(string(msg)))
+}
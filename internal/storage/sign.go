@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoutePrefix is where signed objects are served from.
+const RoutePrefix = "/storage/"
+
+// Signer issues and verifies HMAC-signed, expiring URLs for stored objects.
+type Signer struct {
+	key     []byte
+	baseURL string
+}
+
+// NewSigner derives a dedicated signing key from secret so that URL
+// signatures cannot be replayed as session or JWT material.
+func NewSigner(secret, baseURL string) *Signer {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte("lemme/storage-url/v1"))
+	return &Signer{key: m.Sum(nil), baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// URL returns an absolute signed URL for key, valid for ttl.
+func (s *Signer) URL(key string, ttl time.Duration) string {
+	key = cleanKey(key)
+	if key == "" {
+		return ""
+	}
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	exp := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", s.sign(key, exp))
+	return s.baseURL + RoutePrefix + key + "?" + q.Encode()
+}
+
+// Verify reports whether sig is a valid, unexpired signature of key.
+func (s *Signer) Verify(key string, exp int64, sig string) bool {
+	if time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(s.sign(cleanKey(key), exp)), []byte(sig))
+}
+
+func (s *Signer) sign(key string, exp int64) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(key))
+	m.Write([]byte{0})
+	m.Write([]byte(strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// Serve returns a handler that streams objects of b for requests carrying a
+// valid signature from s. Mount it on RoutePrefix + "*".
+func Serve(b Blob, s *Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := cleanKey(c.Params("*"))
+		if key == "" {
+			return c.Status(404).SendString("not found")
+		}
+		exp, err := strconv.ParseInt(c.Query("exp"), 10, 64)
+		if err != nil || !s.Verify(key, exp, c.Query("sig")) {
+			return c.Status(403).SendString("forbidden")
+		}
+		data, err := b.Get(c.Context(), key)
+		if err != nil {
+			return c.Status(404).SendString("not found")
+		}
+		c.Set(fiber.HeaderContentType, contentTypeOf(key))
+		c.Set(fiber.HeaderCacheControl, "private, max-age="+strconv.FormatInt(max(exp-time.Now().Unix(), 0), 10))
+		return c.Send(data)
+	}
+}
+
+// cleanKey normalizes a stored path ("storage/quizzes/x.jpg", "./storage/...")
+// or route param to an object key ("quizzes/x.jpg"). Keys escaping the root
+// are rejected with "".
+func cleanKey(k string) string {
+	k = path.Clean("/" + filepath.ToSlash(k))
+	k = strings.TrimPrefix(k, "/")
+	k = strings.TrimPrefix(k, strings.Trim(RoutePrefix, "/")+"/")
+	if k == "" || k == "." || strings.Contains(k, "..") {
+		return ""
+	}
+	return k
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// signedParts splits a URL of s into its key, expiry and signature.
+func signedParts(t *testing.T, raw string) (string, int64, string) {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := strconv.ParseInt(u.Query().Get("exp"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimPrefix(u.Path, RoutePrefix), exp, u.Query().Get("sig")
+}
+
+func TestSignerURL(t *testing.T) {
+	s := NewSigner("secret", "https://api.example.com/")
+	raw := s.URL("storage/quizzes/x.jpg", time.Hour)
+	if !strings.HasPrefix(raw, "https://api.example.com/storage/quizzes/x.jpg?") {
+		t.Fatalf("URL = %s", raw)
+	}
+	key, exp, sig := signedParts(t, raw)
+	if d := time.Until(time.Unix(exp, 0)); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v", d)
+	}
+	if !s.Verify(key, exp, sig) {
+		t.Fatal("own signature rejected")
+	}
+
+	tests := []struct {
+		name string
+		s    *Signer
+		key  string
+		exp  int64
+		sig  string
+	}{
+		{"other key", s, "quizzes/y.jpg", exp, sig},
+		{"extended expiry", s, key, exp + 3600, sig},
+		{"tampered signature", s, key, exp, sig[:len(sig)-1] + "A"},
+		{"empty signature", s, key, exp, ""},
+		{"other secret", NewSigner("other", "https://api.example.com"), key, exp, sig},
+	}
+	for _, tt := range tests {
+		if tt.s.Verify(tt.key, tt.exp, tt.sig) {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+
+	if _, exp, _ := signedParts(t, s.URL("quizzes/x.jpg", 0)); time.Until(time.Unix(exp, 0)) > 15*time.Minute {
+		t.Error("a zero ttl does not default to 15 minutes")
+	}
+	if got := s.URL("../x.jpg", time.Hour); strings.Contains(got, "..") {
+		t.Errorf("URL of an escaping key: %s", got)
+	}
+	if got := s.URL("", time.Hour); got != "" {
+		t.Errorf("URL of an empty key: %s", got)
+	}
+}
+
+func TestSignerExpiry(t *testing.T) {
+	s := NewSigner("secret", "")
+	past := time.Now().Add(-time.Second).Unix()
+	if s.Verify("quizzes/x.jpg", past, s.sign("quizzes/x.jpg", past)) {
+		t.Error("expired signature accepted")
+	}
+	future := time.Now().Add(time.Minute).Unix()
+	if !s.Verify("storage/quizzes/x.jpg", future, s.sign("quizzes/x.jpg", future)) {
+		t.Error("signature of the stored path rejected")
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := NewSigner("secret", "")
+	b := NewMemory(s)
+	b.Put(context.Background(), "quizzes/x.png", []byte("png"), "image/png")
+
+	app := fiber.New()
+	app.Get(RoutePrefix+"*", Serve(b, s))
+	get := func(target string) (int, string, string) {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	code, ctype, body := get(s.URL("quizzes/x.png", time.Minute))
+	if code != 200 || ctype != "image/png" || body != "png" {
+		t.Errorf("signed URL: %d %s %q", code, ctype, body)
+	}
+
+	key, exp, sig := signedParts(t, s.URL("quizzes/x.png", time.Minute))
+	past := time.Now().Add(-time.Minute).Unix()
+	tests := []struct {
+		name, target string
+		code         int
+	}{
+		{"no signature", RoutePrefix + key, 403},
+		{"tampered", RoutePrefix + key + "?exp=" + strconv.FormatInt(exp, 10) + "&sig=" + sig + "x", 403},
+		{"other object", RoutePrefix + "quizzes/y.png?exp=" + strconv.FormatInt(exp, 10) + "&sig=" + sig, 403},
+		{"expired", RoutePrefix + key + "?exp=" + strconv.FormatInt(past, 10) + "&sig=" + s.sign(key, past), 403},
+		{"missing object", s.URL("quizzes/gone.png", time.Minute), 404},
+	}
+	for _, tt := range tests {
+		if code, _, _ := get(tt.target); code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
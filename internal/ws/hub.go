@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/emandor/lemme_service/internal/providers"
 	"github.com/emandor/lemme_service/internal/telemetry"
@@ -13,9 +14,42 @@ import (
 
 var (
 	mu    sync.RWMutex
-	rooms = map[string]map[*websocket.Conn]struct{}{}
+	rooms = map[string]map[*conn]struct{}{}
 )
 
+// writeWait bounds one write so a stalled subscriber cannot hold up the
+// goroutine broadcasting to it.
+const writeWait = 10 * time.Second
+
+// conn is a subscriber connection. A websocket connection takes one writer
+// at a time while events of a quiz are broadcast from many goroutines (OCR
+// workers, providers streaming), so every write goes through writeJSON.
+type conn struct {
+	ws  *websocket.Conn
+	wmu sync.Mutex
+}
+
+func (c *conn) writeJSON(v any) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(v)
+}
+
+// broadcast sends pl to every connection of room.
+func broadcast(room string, pl PayloadEvent) {
+	mu.RLock()
+	conns := make([]*conn, 0, len(rooms[room]))
+	for c := range rooms[room] {
+		conns = append(conns, c)
+	}
+	mu.RUnlock()
+
+	for _, c := range conns {
+		_ = c.writeJSON(pl)
+	}
+}
+
 type Action string
 
 const (
@@ -35,7 +69,9 @@ type Event string
 const (
 	EventQuizCreated     Event = "quiz.event.created"
 	EventQuizOCRDone     Event = "quiz.event.ocr_done"
+	EventQuizImageOCR    Event = "quiz.event.image_ocr"
 	EventQuizAnswerAdded Event = "quiz.event.answered"
+	EventQuizAnswerDelta Event = "quiz.event.answer_delta"
 	EventQuizCompleted   Event = "quiz.event.completed"
 	EventQuizError       Event = "quiz.event.error"
 )
@@ -51,7 +87,8 @@ type ClientMessage struct {
 	Room   string `json:"room"`
 }
 
-func HandleWS(c *websocket.Conn) {
+func HandleWS(wc *websocket.Conn) {
+	c := &conn{ws: wc}
 	tlog := telemetry.L().With().Str("module", "ws").Logger()
 	tlog.Info().Msg("ws_connected")
 	defer func() {
@@ -61,11 +98,11 @@ func HandleWS(c *websocket.Conn) {
 			delete(rooms[room], c)
 		}
 		mu.Unlock()
-		_ = c.Close()
+		_ = wc.Close()
 	}()
 
 	for {
-		_, msg, err := c.ReadMessage()
+		_, msg, err := wc.ReadMessage()
 		if err != nil {
 			break
 		}
@@ -84,13 +121,13 @@ func HandleWS(c *websocket.Conn) {
 	}
 }
 
-func joinRoom(c *websocket.Conn, room string) {
+func joinRoom(c *conn, room string) {
 	if room == "" {
 		return
 	}
 	mu.Lock()
 	if rooms[room] == nil {
-		rooms[room] = map[*websocket.Conn]struct{}{}
+		rooms[room] = map[*conn]struct{}{}
 	}
 	rooms[room][c] = struct{}{}
 	mu.Unlock()
@@ -104,7 +141,7 @@ func HasSubscribers(quizID int64) bool {
 	return len(rooms[room]) > 0
 }
 
-func leaveRoom(c *websocket.Conn, room string) {
+func leaveRoom(c *conn, room string) {
 	if room == "" {
 		return
 	}
@@ -124,13 +161,7 @@ func BroadcastNewQuiz(userID, quizID int64, image string) {
 		},
 	}
 
-	mu.RLock()
-	conns := rooms[userRoom]
-	mu.RUnlock()
-
-	for c := range conns {
-		_ = c.WriteJSON(pl)
-	}
+	broadcast(userRoom, pl)
 }
 
 type QuizUpdatePayload struct {
@@ -158,13 +189,7 @@ func BroadcastQuizUpdate(quizID int64, source providers.SourceName, ans *provide
 		pl.Data = err.Error()
 	}
 
-	mu.RLock()
-	conns := rooms[room]
-	mu.RUnlock()
-
-	for c := range conns {
-		_ = c.WriteJSON(pl)
-	}
+	broadcast(room, pl)
 }
 
 func BroadcastQuizCompleted(quizID int64) {
@@ -177,13 +202,7 @@ func BroadcastQuizCompleted(quizID int64) {
 		},
 	}
 
-	mu.RLock()
-	conns := rooms[room]
-	mu.RUnlock()
-
-	for c := range conns {
-		_ = c.WriteJSON(pl)
-	}
+	broadcast(room, pl)
 }
 
 func BroadcastQuizOCRDone(quizID int64, text string) {
@@ -197,11 +216,44 @@ func BroadcastQuizOCRDone(quizID int64, text string) {
 		},
 	}
 
-	mu.RLock()
-	conns := rooms[room]
-	mu.RUnlock()
+	broadcast(room, pl)
+}
 
-	for c := range conns {
-		_ = c.WriteJSON(pl)
+type ImageOCRPayload struct {
+	QuizID   int64  `json:"quiz_id"`
+	Position int    `json:"position"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BroadcastQuizImageOCR reports the OCR status of one image of a quiz.
+func BroadcastQuizImageOCR(quizID int64, position int, status string, err error) {
+	room := string(RoomQuiz) + "." + strconv.FormatInt(quizID, 10)
+
+	data := ImageOCRPayload{QuizID: quizID, Position: position, Status: status}
+	if err != nil {
+		data.Error = err.Error()
 	}
+	pl := PayloadEvent{Event: EventQuizImageOCR, Data: data}
+
+	broadcast(room, pl)
+}
+
+type AnswerDeltaPayload struct {
+	QuizID int64  `json:"quiz_id"`
+	Delta  string `json:"delta"`
+}
+
+// BroadcastAnswerDelta relays a fragment of a provider answer still being
+// generated.
+func BroadcastAnswerDelta(quizID int64, source providers.SourceName, delta string) {
+	room := string(RoomQuiz) + "." + strconv.FormatInt(quizID, 10)
+
+	pl := PayloadEvent{
+		Event:  EventQuizAnswerDelta,
+		Source: source,
+		Data:   AnswerDeltaPayload{QuizID: quizID, Delta: delta},
+	}
+
+	broadcast(room, pl)
 }
@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	fws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/emandor/lemme_service/internal/providers"
+)
+
+// subscribe serves HandleWS and returns a client that joined the room of
+// quizID.
+func subscribe(t *testing.T, quizID int64) *fws.Conn {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws", websocket.New(HandleWS))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	c, _, err := fws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.WriteJSON(ClientMessage{Action: ActionJoin, Room: string(RoomQuiz) + "." + strconv.FormatInt(quizID, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); !HasSubscribers(quizID); {
+		if time.Now().After(deadline) {
+			t.Fatal("join not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return c
+}
+
+// TestConcurrentBroadcasts writes to one subscriber the way a quiz does:
+// OCR workers and streaming providers at once. The connection takes one
+// writer at a time: unserialized writes race (go test -race) or panic.
+func TestConcurrentBroadcasts(t *testing.T) {
+	const quizID, workers, deltas = 41, 3, 50
+	c := subscribe(t, quizID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			BroadcastQuizImageOCR(quizID, i, "processing", nil)
+			BroadcastQuizImageOCR(quizID, i, "done", nil)
+		}()
+		go func() {
+			defer wg.Done()
+			source := providers.SourceName("P" + strconv.Itoa(i))
+			for j := 0; j < deltas; j++ {
+				BroadcastAnswerDelta(quizID, source, "tok")
+			}
+			BroadcastQuizUpdate(quizID, source, nil, nil)
+		}()
+	}
+	wg.Wait()
+	BroadcastQuizCompleted(quizID)
+
+	want := workers*2 + workers*(deltas+1) + 1
+	counts := map[Event]int{}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for n := 0; n < want; n++ {
+		var pl PayloadEvent
+		if err := c.ReadJSON(&pl); err != nil {
+			t.Fatalf("after %d events: %v", n, err)
+		}
+		counts[pl.Event]++
+	}
+	if counts[EventQuizAnswerDelta] != workers*deltas || counts[EventQuizImageOCR] != workers*2 || counts[EventQuizCompleted] != 1 {
+		t.Errorf("events %v", counts)
+	}
+}